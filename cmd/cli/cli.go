@@ -3,13 +3,14 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
-	"mime/multipart"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -30,6 +31,12 @@ var (
 	yellow = color.New(color.FgYellow).SprintFunc()
 )
 
+// The session's refresh token, used to renew the access token before it expires
+var (
+	refreshToken string
+	tokenExpiry  time.Time
+)
+
 func main() {
 	checkServerConnection()
 
@@ -92,13 +99,10 @@ func login() {
 		return
 	}
 
-	tokenData, ok := respMap["token"].(string)
-	if !ok {
+	if !setSession(respMap) {
 		fmt.Println(red("Token missing in response"))
 		return
 	}
-
-	token = tokenData
 	fmt.Println(green("\nLogin successful!"))
 
 	role, _ := respMap["role"].(string)
@@ -109,6 +113,78 @@ func login() {
 	}
 }
 
+// setSession stores the tokens from a login or refresh response
+func setSession(respMap map[string]interface{}) bool {
+	accessToken, ok := respMap["token"].(string)
+	if !ok {
+		return false
+	}
+	token = accessToken
+	refreshToken, _ = respMap["refresh_token"].(string)
+	tokenExpiry, _ = time.Parse(time.RFC3339, fmt.Sprint(respMap["expires_at"]))
+	return true
+}
+
+// authorize adds the access token to a request, first refreshing it if it is
+// about to expire
+func authorize(req *http.Request) {
+	if token == "" {
+		return
+	}
+	if refreshToken != "" && time.Until(tokenExpiry) < 30*time.Second {
+		if err := refreshSession(); err != nil {
+			fmt.Println(yellow("Could not refresh session, please log in again:", err))
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+}
+
+// refreshSession exchanges the refresh token for new tokens. It does not use
+// sendRequest, which would try to refresh again.
+func refreshSession() error {
+	body, err := json.Marshal(map[string]string{"refresh_token": refreshToken})
+	if err != nil {
+		return err
+	}
+	resp, err := client.Post(baseURL+"/refresh", "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	var respMap map[string]interface{}
+	if err := json.NewDecoder(resp.Body).Decode(&respMap); err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		refreshToken = ""
+		return fmt.Errorf("%v", respMap["error"])
+	}
+	if !setSession(respMap) {
+		return fmt.Errorf("token missing in response")
+	}
+	return nil
+}
+
+// logout ends the session on the server, or every session of the user if
+// all is set, and forgets the tokens
+func logout(all bool) {
+	path := "/logout"
+	if all {
+		path = "/logout-all"
+	}
+	if _, err := sendRequest("POST", path, nil, nil); err != nil {
+		fmt.Println(red("Failed to log out on the server:", err))
+	}
+
+	token, refreshToken, tokenExpiry = "", "", time.Time{}
+	if all {
+		fmt.Println(green("Logged out of all sessions"))
+	} else {
+		fmt.Println(green("Logged out successfully"))
+	}
+}
+
 func register() {
 	fmt.Print("Username: ")
 	username, _ := reader.ReadString('\n')
@@ -159,8 +235,10 @@ func postLoginMenu() {
 		if role == "admin" {
 			fmt.Println("6. Admin Dashboard")
 			fmt.Println("7. Logout")
+			fmt.Println("8. Log out all sessions")
 		} else {
 			fmt.Println("6. Logout")
+			fmt.Println("7. Log out all sessions")
 		}
 
 		fmt.Print("Choose an option: ")
@@ -183,14 +261,15 @@ func postLoginMenu() {
 			if role == "admin" {
 				adminDashboardMenu()
 			} else {
-				token = ""
-				fmt.Println(green("Logged out successfully"))
+				logout(false)
 				return
 			}
 		case "7":
+			logout(role != "admin")
+			return
+		case "8":
 			if role == "admin" {
-				token = ""
-				fmt.Println(green("Logged out successfully"))
+				logout(true)
 				return
 			} else {
 				fmt.Println(red("Invalid option"))
@@ -207,7 +286,8 @@ func adminDashboardMenu() {
 		fmt.Println("1. List All Users")
 		fmt.Println("2. Delete User")
 		fmt.Println("3. View All Tasks")
-		fmt.Println("4. Back to Main Menu")
+		fmt.Println("4. Check Storage Consistency")
+		fmt.Println("5. Back to Main Menu")
 		fmt.Print("Choose an option: ")
 
 		option, _ := reader.ReadString('\n')
@@ -221,6 +301,8 @@ func adminDashboardMenu() {
 		case "3":
 			viewAllTasks()
 		case "4":
+			checkStorage()
+		case "5":
 			return
 		default:
 			fmt.Println(red("Invalid option"))
@@ -283,6 +365,50 @@ func viewAllTasks() {
 	}
 }
 
+func checkStorage() {
+	resp, err := sendRequest("POST", "/admin/storage/check", nil, nil)
+	if err != nil {
+		fmt.Println(red("Failed to check storage:", err))
+		return
+	}
+	report, ok := resp.(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	fmt.Printf("\nObjects in storage: %v\n", report["objects"])
+	problems := 0
+	for _, field := range []struct{ key, label string }{
+		{"missing_contents", "Files with missing contents"},
+		{"missing_blobs", "Missing blobs"},
+		{"ref_count_mismatches", "Reference count mismatches"},
+		{"unreferenced_blobs", "Unreferenced blobs"},
+		{"orphaned_objects", "Orphaned objects"},
+	} {
+		items, _ := report[field.key].([]interface{})
+		problems += len(items)
+		fmt.Printf("%s: %d\n", field.label, len(items))
+	}
+	if problems == 0 {
+		fmt.Println(green("Storage is consistent"))
+		return
+	}
+	fmt.Printf("Orphaned bytes: %v\n", report["orphaned_bytes"])
+
+	fmt.Print(red("Repair these problems? Files with missing contents will be deleted (y/n): "))
+	confirm, _ := reader.ReadString('\n')
+	confirm = strings.TrimSpace(strings.ToLower(confirm))
+	if confirm != "y" && confirm != "yes" {
+		fmt.Println(yellow("Repair cancelled"))
+		return
+	}
+	if _, err := sendRequest("POST", "/admin/storage/check?repair=true", nil, nil); err != nil {
+		fmt.Println(red("Failed to repair storage:", err))
+		return
+	}
+	fmt.Println(green("Storage repaired"))
+}
+
 func taskManagementMenu() {
 	for {
 		fmt.Printf("\n%s\n", blue("Task Management"))
@@ -592,31 +718,201 @@ func uploadFile() {
 	filePath, _ := reader.ReadString('\n')
 	filePath = strings.TrimSpace(filePath)
 
-	file, err := os.Open(filePath)
+	fileID, err := resumableUpload(taskID, filePath)
 	if err != nil {
-		fmt.Println(red("Error opening file:", err))
+		fmt.Println(red("Failed to upload file:", err))
+		fmt.Println(yellow("Run the upload again with the same file to resume it"))
 		return
 	}
+	fmt.Println(green("File uploaded successfully. File ID:", fileID))
+}
+
+// uploadChunkSize is how much of a file is sent per tus PATCH request
+const uploadChunkSize = 5 << 20
+
+// uploadStatePath is where the URLs of unfinished uploads are remembered so an
+// interrupted upload can be resumed by a later run
+func uploadStatePath() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "taskmanager", "uploads.json")
+}
+
+func loadUploadState() map[string]string {
+	state := make(map[string]string)
+	if data, err := os.ReadFile(uploadStatePath()); err == nil {
+		json.Unmarshal(data, &state)
+	}
+	return state
+}
+
+func saveUploadState(state map[string]string) {
+	path := uploadStatePath()
+	os.MkdirAll(filepath.Dir(path), 0o700)
+	if data, err := json.Marshal(state); err == nil {
+		os.WriteFile(path, data, 0o600)
+	}
+}
+
+// resumableUpload sends a file with the tus protocol, resuming a previous
+// attempt at the same file if the server still has it. It returns the ID of
+// the created file.
+func resumableUpload(taskID, filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
 	defer file.Close()
 
-	body := &bytes.Buffer{}
-	writer := multipart.NewWriter(body)
-	part, err := writer.CreateFormFile("file", filepath.Base(file.Name()))
+	info, err := file.Stat()
 	if err != nil {
-		fmt.Println(red("Error creating form file:", err))
-		return
+		return "", err
 	}
-	io.Copy(part, file)
-	writer.Close()
+	absPath, _ := filepath.Abs(filePath)
+	stateKey := fmt.Sprintf("%s|%s|%d|%d", taskID, absPath, info.Size(), info.ModTime().Unix())
 
-	headers := map[string]string{
-		"Content-Type": writer.FormDataContentType(),
+	state := loadUploadState()
+	uploadURL := state[stateKey]
+	var offset int64 = -1
+	if uploadURL != "" {
+		if offset, err = tusOffset(uploadURL); err == nil {
+			fmt.Println(yellow(fmt.Sprintf("Resuming upload at %d of %d bytes", offset, info.Size())))
+		} else {
+			offset = -1 // Expired or unknown; start over
+		}
+	}
+	if offset < 0 {
+		if uploadURL, err = tusCreate(taskID, filepath.Base(filePath), info.Size()); err != nil {
+			return "", err
+		}
+		offset = 0
+		state[stateKey] = uploadURL
+		saveUploadState(state)
 	}
 
-	_, err = sendRequest("POST", "/tasks/"+taskID+"/files", body, headers)
+	fileID := ""
+	retries := 0
+	for {
+		if _, err := file.Seek(offset, io.SeekStart); err != nil {
+			return "", err
+		}
+		chunk := io.LimitReader(file, uploadChunkSize)
+		size := info.Size() - offset
+		if size > uploadChunkSize {
+			size = uploadChunkSize
+		}
+
+		newOffset, id, err := tusPatch(uploadURL, offset, chunk, size)
+		if err != nil {
+			// Ask the server how much arrived and carry on from there
+			retries++
+			if retries > 3 {
+				return "", err
+			}
+			fmt.Println(yellow("\nChunk failed, retrying:", err))
+			time.Sleep(time.Duration(retries) * time.Second)
+			if newOffset, err = tusOffset(uploadURL); err != nil {
+				return "", err
+			}
+		} else {
+			retries = 0
+		}
+		offset, fileID = newOffset, id
+		fmt.Printf("\rUploaded %d of %d bytes", offset, info.Size())
+		if offset >= info.Size() && fileID != "" {
+			fmt.Println()
+			break
+		}
+	}
+
+	delete(state, stateKey)
+	saveUploadState(state)
+	return fileID, nil
+}
+
+func tusRequest(method, url string, body io.Reader) (*http.Request, error) {
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
-		fmt.Println(red("Failed to upload file:", err))
+		return nil, err
+	}
+	req.Header.Set("Tus-Resumable", "1.0.0")
+	authorize(req)
+	return req, nil
+}
+
+func tusCreate(taskID, name string, size int64) (string, error) {
+	req, err := tusRequest("POST", baseURL+"/uploads", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Upload-Length", fmt.Sprint(size))
+	req.Header.Set("Upload-Metadata", "task_id "+base64.StdEncoding.EncodeToString([]byte(taskID))+
+		",filename "+base64.StdEncoding.EncodeToString([]byte(name)))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusCreated {
+		body, _ := io.ReadAll(resp.Body)
+		return "", fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
+	}
+
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return "", err
+	}
+	return location.String(), nil
+}
+
+func tusOffset(uploadURL string) (int64, error) {
+	req, err := tusRequest("HEAD", uploadURL, nil)
+	if err != nil {
+		return 0, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	return strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+}
+
+// tusPatch sends one chunk and returns the new offset, and the file ID once
+// the upload is complete
+func tusPatch(uploadURL string, offset int64, chunk io.Reader, size int64) (int64, string, error) {
+	req, err := tusRequest("PATCH", uploadURL, chunk)
+	if err != nil {
+		return offset, "", err
+	}
+	req.ContentLength = size
+	if size == 0 {
+		req.Body = http.NoBody
+	}
+	req.Header.Set("Content-Type", "application/offset+octet-stream")
+	req.Header.Set("Upload-Offset", fmt.Sprint(offset))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return offset, "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent {
+		body, _ := io.ReadAll(resp.Body)
+		return offset, "", fmt.Errorf("server returned %d: %s", resp.StatusCode, string(body))
 	}
+
+	newOffset, err := strconv.ParseInt(resp.Header.Get("Upload-Offset"), 10, 64)
+	if err != nil {
+		return offset, "", err
+	}
+	return newOffset, resp.Header.Get("Upload-File-Id"), nil
 }
 
 func downloadFile() {
@@ -634,9 +930,7 @@ func downloadFile() {
 		return
 	}
 
-	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
+	authorize(req)
 
 	resp, err := client.Do(req)
 	if err != nil {
@@ -693,8 +987,14 @@ func notificationMenu() {
 		return
 	}
 
-	if notifications, ok := resp.([]interface{}); ok {
-		fmt.Println(green("\nYour Notifications:"))
+	respMap, ok := resp.(map[string]interface{})
+	if !ok {
+		fmt.Println(red("Invalid notifications response"))
+		return
+	}
+
+	if notifications, ok := respMap["notifications"].([]interface{}); ok {
+		fmt.Printf("\n%s (%v total)\n", green("Your Notifications:"), respMap["total"])
 		for i, notification := range notifications {
 			notifMap := notification.(map[string]interface{})
 			status := notifMap["status"].(string)
@@ -709,7 +1009,9 @@ func notificationMenu() {
 		}
 
 		fmt.Println("\n1. Mark notification as read")
-		fmt.Println("2. Back to main menu")
+		fmt.Println("2. Mark all notifications as read")
+		fmt.Println("3. Delete read notifications")
+		fmt.Println("4. Back to main menu")
 		fmt.Print("Choose an option: ")
 
 		option, _ := reader.ReadString('\n')
@@ -719,6 +1021,10 @@ func notificationMenu() {
 		case "1":
 			markNotificationAsRead()
 		case "2":
+			markAllNotificationsAsRead()
+		case "3":
+			deleteReadNotifications()
+		case "4":
 			return
 		default:
 			fmt.Println(red("Invalid option"))
@@ -739,6 +1045,32 @@ func markNotificationAsRead() {
 	}
 }
 
+func markAllNotificationsAsRead() {
+	_, err := sendRequest("PUT", "/notifications/read-all", nil, nil)
+	if err != nil {
+		fmt.Println(red("Failed to mark notifications as read:", err))
+	} else {
+		fmt.Println(green("All notifications marked as read"))
+	}
+}
+
+func deleteReadNotifications() {
+	fmt.Print(red("Are you sure you want to delete all read notifications? (y/n): "))
+	confirm, _ := reader.ReadString('\n')
+	confirm = strings.TrimSpace(strings.ToLower(confirm))
+
+	if confirm == "y" || confirm == "yes" {
+		_, err := sendRequest("DELETE", "/notifications", map[string]string{"status": "read"}, nil)
+		if err != nil {
+			fmt.Println(red("Failed to delete notifications:", err))
+		} else {
+			fmt.Println(green("Read notifications deleted"))
+		}
+	} else {
+		fmt.Println(yellow("Deletion cancelled"))
+	}
+}
+
 func userProfileMenu() {
 	for {
 		resp, err := sendRequest("GET", "/profile", nil, nil)
@@ -845,10 +1177,8 @@ func sendRequest(method, path string, body interface{}, headers map[string]strin
 		}
 	}
 
-	// Add authorization header if token exists
-	if token != "" {
-		req.Header.Set("Authorization", "Bearer "+token)
-	}
+	// Add authorization header if logged in
+	authorize(req)
 
 	// Set content type for JSON requests
 	if body != nil && headers == nil {
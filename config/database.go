@@ -13,13 +13,9 @@ import (
 
 var DB *gorm.DB
 
-func ConnectDatabase() {
-	err := godotenv.Load()
-	if err != nil {
-		panic("Error loading .env file")
-	}
-
-	dsn := fmt.Sprintf(
+// DSN builds the Postgres connection string from the DB_* environment variables
+func DSN() string {
+	return fmt.Sprintf(
 		"host=%s user=%s password=%s dbname=%s port=%s sslmode=disable TimeZone=UTC",
 		os.Getenv("DB_HOST"),
 		os.Getenv("DB_USER"),
@@ -27,6 +23,15 @@ func ConnectDatabase() {
 		os.Getenv("DB_NAME"),
 		os.Getenv("DB_PORT"),
 	)
+}
+
+func ConnectDatabase() {
+	err := godotenv.Load()
+	if err != nil {
+		panic("Error loading .env file")
+	}
+
+	dsn := DSN()
 
 	var db *gorm.DB
 
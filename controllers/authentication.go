@@ -8,8 +8,10 @@ import (
 
 	"github.com/Chamanthra/TaskManager/config"
 	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/sessions"
 	"github.com/Chamanthra/TaskManager/utils"
 	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
 
@@ -17,8 +19,8 @@ func Register(c *gin.Context) {
 	var input struct {
 		UserName string `json:"user_name" binding:"required"`
 		Password string `json:"password" binding:"required"`
-		RoleID   uint   `json:"role_id"` // Optional
-		Role     string `json:"role"`    // Optional
+		RoleID   uint   `json:"role_id"` // Rejected; roles are assigned by admins
+		Role     string `json:"role"`    // Rejected unless it is the default role
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -41,22 +43,15 @@ func Register(c *gin.Context) {
 		return
 	}
 
-	// Find role: by ID or by name
+	// Users can only register with the default role; an admin assigns others
+	if input.RoleID != 0 || (input.Role != "" && input.Role != models.DefaultRole) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only the default role can be chosen when registering"})
+		return
+	}
 	var role models.Role
-	if input.RoleID != 0 {
-		if err := config.DB.First(&role, input.RoleID).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
-			return
-		}
-	} else {
-		// Default to "user" if role name not provided
-		if input.Role == "" {
-			input.Role = "user"
-		}
-		if err := config.DB.Where("role = ?", input.Role).First(&role).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role name"})
-			return
-		}
+	if err := config.DB.Where("role = ?", models.DefaultRole).First(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Default role is missing"})
+		return
 	}
 
 	// Hash password
@@ -125,16 +120,18 @@ func Login(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT with role name
-	token, err := utils.GenerateJWT(user.ID, user.Role.Name) // Use Role.Name instead of Role struct
+	// Start a session with a short-lived access token and a refresh token
+	tokens, err := sessions.Start(config.DB, &user)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate token", "details": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"message": "Login successful",
-		"token":   token,
+		"message":       "Login successful",
+		"token":         tokens.AccessToken,
+		"expires_at":    tokens.ExpiresAt,
+		"refresh_token": tokens.RefreshToken,
 		"user": gin.H{
 			"id":       user.ID,
 			"username": user.UserName,
@@ -142,3 +139,76 @@ func Login(c *gin.Context) {
 		},
 	})
 }
+
+// RefreshToken exchanges a refresh token for a new access token and refresh
+// token. Each refresh token works once; presenting one again revokes its
+// session.
+func RefreshToken(c *gin.Context) {
+	var input struct {
+		RefreshToken string `json:"refresh_token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format", "details": err.Error()})
+		return
+	}
+
+	tokens, err := sessions.Refresh(config.DB, input.RefreshToken)
+	switch {
+	case errors.Is(err, sessions.ErrRefreshTokenReused):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Refresh token was already used; the session has been revoked"})
+		return
+	case errors.Is(err, sessions.ErrInvalidRefreshToken):
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired refresh token"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to refresh token", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout revokes the caller's access token and ends its session. Tokens
+// issued before sessions were introduced carry no token ID and cannot be
+// revoked one by one, so logging out with one ends all of the user's
+// sessions.
+func Logout(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+	jti, _ := claims["jti"].(string)
+	sessionID, _ := claims["sid"].(string)
+	expiresAt, err := claims.GetExpirationTime()
+	if err != nil || expiresAt == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	if jti == "" {
+		if err := sessions.LogoutAll(config.DB, userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+		return
+	}
+
+	if err := sessions.Logout(config.DB, userID, jti, expiresAt.Time, sessionID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out"})
+}
+
+// LogoutAll ends every session of the caller, on all devices
+func LogoutAll(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	if err := sessions.LogoutAll(config.DB, userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log out"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Logged out of all sessions"})
+}
@@ -5,16 +5,18 @@ import (
 	"strconv"
 
 	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/events"
+	"github.com/Chamanthra/TaskManager/middlewares"
 	"github.com/Chamanthra/TaskManager/models"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
 // AddComment adds a comment to a task
 func AddComment(c *gin.Context) {
 	claims := c.MustGet("claims").(jwt.MapClaims)
 	userID := uint(claims["user_id"].(float64))
-	role := claims["role"].(string)
 
 	taskID, err := strconv.Atoi(c.Param("taskId"))
 	if err != nil {
@@ -29,8 +31,8 @@ func AddComment(c *gin.Context) {
 		return
 	}
 
-	// Check ownership (unless admin)
-	if role != "admin" && task.UserID != userID {
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && task.UserID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only comment on your own tasks"})
 		return
 	}
@@ -50,22 +52,18 @@ func AddComment(c *gin.Context) {
 		UserID:  userID,
 	}
 
-	if err := config.DB.Create(&comment).Error; err != nil {
+	// The task owner is notified by the comment.created event subscriber
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&comment).Error; err != nil {
+			return err
+		}
+		return events.Record(tx, events.Event{Type: events.CommentCreated, TaskID: task.ID, OwnerID: task.UserID, ActorID: userID, Data: comment})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add comment"})
 		return
 	}
 
-	// Create notification for task owner
-	if task.UserID != userID { // Don't notify yourself
-		notification := models.Notification{
-			Message: "New comment added to your task",
-			UserID:  task.UserID,
-			TaskID:  task.ID,
-			Type:    "comment",
-		}
-		config.DB.Create(&notification)
-	}
-
 	c.JSON(http.StatusCreated, comment)
 }
 
@@ -73,7 +71,6 @@ func AddComment(c *gin.Context) {
 func DeleteComment(c *gin.Context) {
 	claims := c.MustGet("claims").(jwt.MapClaims)
 	userID := uint(claims["user_id"].(float64))
-	role := claims["role"].(string)
 
 	commentID, err := strconv.Atoi(c.Param("commentId"))
 	if err != nil {
@@ -87,13 +84,19 @@ func DeleteComment(c *gin.Context) {
 		return
 	}
 
-	// Check ownership (admin can delete any, users can only delete their own)
-	if role != "admin" && comment.UserID != userID {
+	// Check ownership (users can only delete their own unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && comment.UserID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own comments"})
 		return
 	}
 
-	if err := config.DB.Delete(&comment).Error; err != nil {
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&comment).Error; err != nil {
+			return err
+		}
+		return events.Record(tx, events.Event{Type: events.CommentDeleted, TaskID: comment.TaskID, OwnerID: comment.Task.UserID, ActorID: userID, Data: comment})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete comment"})
 		return
 	}
@@ -1,28 +1,28 @@
 package controllers
 
 import (
+	"context"
+	"errors"
+	"log"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
 
 	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/events"
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/Chamanthra/TaskManager/middlewares"
 	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/storage"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
 )
 
-const uploadDir = "./uploads"
-
-func init() {
-	os.MkdirAll(uploadDir, os.ModePerm)
-}
-
 // UploadFile handles file uploads for tasks
 func UploadFile(c *gin.Context) {
 	claims := c.MustGet("claims").(jwt.MapClaims)
 	userID := uint(claims["user_id"].(float64))
-	role := claims["role"].(string)
 
 	taskID, err := strconv.Atoi(c.Param("taskId"))
 	if err != nil {
@@ -37,122 +37,239 @@ func UploadFile(c *gin.Context) {
 		return
 	}
 
-	// Check ownership (unless admin)
-	if role != "admin" && task.UserID != userID {
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && task.UserID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only upload files to your own tasks"})
 		return
 	}
 
-	file, err := c.FormFile("file")
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "File upload error"})
+	upload, ok := spoolFormFile(c)
+	if !ok {
 		return
 	}
+	defer upload.Close()
 
-	// Save file
-	filename := filepath.Join(uploadDir, strconv.Itoa(int(taskID))+"_"+file.Filename)
-	if err := c.SaveUploadedFile(file, filename); err != nil {
+	fileRecord, err := saveUpload(c.Request.Context(), &task, userID, 0, upload)
+	if errors.Is(err, files.ErrQuotaExceeded) {
+		respondUploadError(c, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save upload for task %d: %v", task.ID, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
 		return
 	}
 
-	// Create file record
+	c.JSON(http.StatusCreated, newFileResponse(fileRecord))
+}
+
+// spoolFormFile reads the "file" field of a multipart upload within the
+// configured size limits, responding with an error if the file is rejected
+func spoolFormFile(c *gin.Context) (*files.Upload, bool) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, files.MaxRequestSize())
+
+	header, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			err = files.ErrFileTooLarge
+		}
+		respondUploadError(c, err)
+		return nil, false
+	}
+	if header.Size > files.MaxFileSize() {
+		respondUploadError(c, files.ErrFileTooLarge)
+		return nil, false
+	}
+
+	src, err := header.Open()
+	if err != nil {
+		respondUploadError(c, err)
+		return nil, false
+	}
+	defer src.Close()
+
+	upload, err := files.Spool(src, header.Filename)
+	if err != nil {
+		respondUploadError(c, err)
+		return nil, false
+	}
+	return upload, true
+}
+
+// respondUploadError reports why an upload was rejected
+func respondUploadError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, files.ErrFileTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File is too large", "max_size": files.MaxFileSize()})
+	case errors.Is(err, files.ErrTypeNotAllowed):
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "File type is not allowed"})
+	case errors.Is(err, files.ErrQuotaExceeded):
+		var quota *files.QuotaError
+		errors.As(err, &quota)
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Storage quota exceeded", "used": quota.Used, "quota": quota.Quota, "size": quota.Size})
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "File upload error"})
+	}
+}
+
+// saveUpload stores a spooled upload and records it as a file of the task,
+// or as a new version of an existing file if originID is not zero
+func saveUpload(ctx context.Context, task *models.Task, userID, originID uint, upload *files.Upload) (*models.File, error) {
 	fileRecord := models.File{
-		FilePath: filename,
-		FileName: file.Filename,
-		FileType: file.Header.Get("Content-Type"),
-		FileSize: file.Size,
-		TaskID:   uint(taskID),
+		TaskID:   task.ID,
 		UserID:   userID,
+		OriginID: originID,
 	}
 
-	if err := config.DB.Create(&fileRecord).Error; err != nil {
-		os.Remove(filename) // Clean up if DB fails
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create file record"})
-		return
+	// The task owner is notified by the file.uploaded event subscriber
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := files.Attach(ctx, tx, upload, &fileRecord); err != nil {
+			return err
+		}
+		return events.Record(tx, events.Event{Type: events.FileUploaded, TaskID: task.ID, OwnerID: task.UserID, ActorID: userID, Data: newFileResponse(&fileRecord)})
+	})
+	if err != nil {
+		return nil, err
 	}
+	return &fileRecord, nil
+}
 
-	// Create notification for task owner
-	if task.UserID != userID { // Don't notify yourself
-		notification := models.Notification{
-			Message: "New file uploaded to your task",
-			UserID:  task.UserID,
-			TaskID:  task.ID,
-			Type:    "file_upload",
-		}
-		config.DB.Create(&notification)
+// DownloadFile handles file downloads. The current version is served unless
+// ?version= names another one.
+func DownloadFile(c *gin.Context) {
+	file, ok := loadDownloadableFile(c)
+	if !ok {
+		return
 	}
 
-	c.JSON(http.StatusCreated, fileRecord)
+	serveFile(c, file)
 }
 
-// DownloadFile handles file downloads
-func DownloadFile(c *gin.Context) {
+// loadDownloadableFile loads the requested version of the file named by the
+// fileId parameter, checking that the caller may download it and that it
+// passed the virus scan
+func loadDownloadableFile(c *gin.Context) (*models.File, bool) {
 	claims := c.MustGet("claims").(jwt.MapClaims)
 	userID := uint(claims["user_id"].(float64))
-	role := claims["role"].(string)
 
-	fileID, err := strconv.Atoi(c.Param("fileId"))
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
-		return
+	file, ok := loadFile(c)
+	if !ok {
+		return nil, false
 	}
 
-	var file models.File
-	if err := config.DB.Preload("Task").First(&file, fileID).Error; err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-		return
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && file.Task.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only download files from your own tasks"})
+		return nil, false
 	}
 
-	// Check ownership (unless admin)
-	if role != "admin" && file.Task.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only download files from your own tasks"})
-		return
+	version, err := strconv.Atoi(c.DefaultQuery("version", "0"))
+	if err != nil || version < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return nil, false
+	}
+	requested, err := files.Version(config.DB, file.OriginID, version)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return nil, false
 	}
 
-	if _, err := os.Stat(file.FilePath); os.IsNotExist(err) {
+	// Only files that passed the virus scan can be downloaded
+	switch requested.ScanStatus {
+	case models.ScanClean:
+		return requested, true
+	case models.ScanInfected:
+		c.JSON(http.StatusForbidden, gin.H{"error": "File is infected and has been quarantined", "scan_status": requested.ScanStatus})
+	default:
+		c.JSON(http.StatusConflict, gin.H{"error": "File has not passed the virus scan yet", "scan_status": requested.ScanStatus})
+	}
+	return nil, false
+}
+
+// serveFile streams a file's contents as an attachment, honouring Range,
+// If-Range and conditional request headers
+func serveFile(c *gin.Context, file *models.File) {
+	object, err := storage.Default.Stat(c.Request.Context(), file.FilePath)
+	if errors.Is(err, storage.ErrNotFound) {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found on server"})
 		return
 	}
+	if err != nil {
+		log.Printf("Failed to read file %s: %v", file.FilePath, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read file from storage"})
+		return
+	}
 
-	c.FileAttachment(file.FilePath, file.FileName)
-}
+	contents := storage.NewSeeker(c.Request.Context(), storage.Default, file.FilePath, object.Size)
+	defer contents.Close()
 
-// DeleteFile handles file deletion
-func DeleteFile(c *gin.Context) {
-	claims := c.MustGet("claims").(jwt.MapClaims)
-	userID := uint(claims["user_id"].(float64))
-	role := claims["role"].(string)
+	contentType := file.FileType
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": file.FileName}))
+	if file.BlobHash != "" {
+		// The content hash is a strong validator for If-Range and If-None-Match
+		c.Header("ETag", `"`+file.BlobHash+`"`)
+	}
+	http.ServeContent(c.Writer, c.Request, file.FileName, file.UploadedAt, contents)
+}
 
+// loadFile loads the file named by the fileId parameter along with its task,
+// responding with an error if it does not exist
+func loadFile(c *gin.Context) (*models.File, bool) {
 	fileID, err := strconv.Atoi(c.Param("fileId"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
-		return
+		return nil, false
 	}
 
 	var file models.File
 	if err := config.DB.Preload("Task").First(&file, fileID).Error; err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
-		return
+		return nil, false
 	}
+	return &file, true
+}
 
-	// Check ownership (admin can delete any, users can only delete their own)
-	if role != "admin" && file.UserID != userID {
-		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own files"})
+// DeleteFile handles file deletion, removing every version of the file
+func DeleteFile(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	file, ok := loadFile(c)
+	if !ok {
 		return
 	}
 
-	// Delete file from filesystem
-	if err := os.Remove(file.FilePath); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file from storage"})
+	// Check ownership (users can only delete their own unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && file.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own files"})
 		return
 	}
 
-	// Delete record from database
-	if err := config.DB.Delete(&file).Error; err != nil {
+	// Delete records from database
+	var versions []models.File
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if versions, err = files.Detach(tx, file); err != nil {
+			return err
+		}
+		return events.Record(tx, events.Event{Type: events.FileDeleted, TaskID: file.TaskID, OwnerID: file.Task.UserID, ActorID: userID, Data: newFileResponse(file)})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete file record"})
 		return
 	}
 
+	// Delete contents from storage unless another file shares them. The record
+	// is removed first so a storage failure leaves an orphaned object rather
+	// than a record without contents.
+	if err := files.Purge(c.Request.Context(), versions); err != nil {
+		log.Printf("Failed to delete file %s from storage: %v", file.FilePath, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "File deleted"})
 }
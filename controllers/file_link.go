@@ -0,0 +1,100 @@
+package controllers
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/utils"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultLinkExpiry = 15 * time.Minute
+	maxLinkExpiry     = 24 * time.Hour
+)
+
+// CreateDownloadLink mints a signed URL from which one version of a file can
+// be downloaded without the Authorization header until it expires.
+// ?expires_in= sets the lifetime in seconds (default 15 minutes, at most 24
+// hours) and ?version= selects a version as for DownloadFile.
+func CreateDownloadLink(c *gin.Context) {
+	file, ok := loadDownloadableFile(c)
+	if !ok {
+		return
+	}
+
+	expiry := defaultLinkExpiry
+	if value := c.Query("expires_in"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds <= 0 || time.Duration(seconds)*time.Second > maxLinkExpiry {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must be between 1 and 86400 seconds"})
+			return
+		}
+		expiry = time.Duration(seconds) * time.Second
+	}
+
+	// The link names the concrete version, so restoring another version later
+	// does not change what it downloads
+	expiresAt := time.Now().Add(expiry).Truncate(time.Second)
+	signature, err := utils.SignDownload(file.ID, expiresAt)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign download link"})
+		return
+	}
+
+	query := url.Values{}
+	query.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	query.Set("signature", signature)
+	link := url.URL{
+		Scheme:   requestScheme(c),
+		Host:     c.Request.Host,
+		Path:     "/api/files/" + strconv.FormatUint(uint64(file.ID), 10) + "/download",
+		RawQuery: query.Encode(),
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"url": link.String(), "expires_at": expiresAt})
+}
+
+// DownloadSignedFile serves a file named by a link from CreateDownloadLink.
+// The signature stands in for authentication.
+func DownloadSignedFile(c *gin.Context) {
+	fileID, err := strconv.ParseUint(c.Param("fileId"), 10, 0)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+		return
+	}
+	expires, err := strconv.ParseInt(c.Query("expires"), 10, 64)
+	if err != nil || !utils.VerifyDownload(uint(fileID), expires, c.Query("signature")) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Invalid or expired download link"})
+		return
+	}
+
+	var file models.File
+	if err := config.DB.First(&file, fileID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+		return
+	}
+	// The file may have been found infected after the link was minted
+	if file.ScanStatus != models.ScanClean {
+		c.JSON(http.StatusForbidden, gin.H{"error": "File is not available for download", "scan_status": file.ScanStatus})
+		return
+	}
+
+	serveFile(c, &file)
+}
+
+// requestScheme returns the scheme the client used, trusting
+// X-Forwarded-Proto from a reverse proxy
+func requestScheme(c *gin.Context) string {
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto == "http" || proto == "https" {
+		return proto
+	}
+	if c.Request.TLS != nil {
+		return "https"
+	}
+	return "http"
+}
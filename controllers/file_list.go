@@ -0,0 +1,119 @@
+package controllers
+
+import (
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/Chamanthra/TaskManager/middlewares"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// GetTaskFiles lists a page of the current versions of a task's attachments,
+// newest first
+func GetTaskFiles(c *gin.Context) {
+	task, ok := loadTaskFiles(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Model(&models.File{}).Where("task_id = ? AND is_current", task.ID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get files"})
+		return
+	}
+
+	page, pageSize, offset := pagination(c)
+	var taskFiles []models.File
+	if err := query.Preload("User", files.UploaderColumns).Order("uploaded_at desc, id desc").Offset(offset).Limit(pageSize).Find(&taskFiles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get files"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"files":     newFileResponses(taskFiles),
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// DownloadTaskFilesArchive streams a zip archive of the current versions of a
+// task's attachments. ?ids= limits it to a comma-separated list of file IDs.
+// Files that have not passed the virus scan are left out.
+func DownloadTaskFilesArchive(c *gin.Context) {
+	task, ok := loadTaskFiles(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("task_id = ? AND is_current AND scan_status = ?", task.ID, models.ScanClean)
+	if value := c.Query("ids"); value != "" {
+		var ids []uint
+		for _, part := range strings.Split(value, ",") {
+			id, err := strconv.ParseUint(strings.TrimSpace(part), 10, 0)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID in ids"})
+				return
+			}
+			ids = append(ids, uint(id))
+		}
+		query = query.Where("id IN ?", ids)
+	}
+
+	var taskFiles []models.File
+	if err := query.Order("id").Find(&taskFiles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get files"})
+		return
+	}
+	if len(taskFiles) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No downloadable files"})
+		return
+	}
+
+	name := "task-" + strconv.FormatUint(uint64(task.ID), 10) + "-files.zip"
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.Status(http.StatusOK)
+
+	// The status has been sent, so a failure part way can only cut the
+	// archive short; clients see a truncated zip
+	if err := files.WriteArchive(c.Request.Context(), c.Writer, taskFiles); err != nil {
+		log.Printf("Failed to stream archive of task %d: %v", task.ID, err)
+		c.Abort()
+	}
+}
+
+// loadTaskFiles loads the task named by the taskId parameter, checking that
+// the caller may see its files
+func loadTaskFiles(c *gin.Context) (*models.Task, bool) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	taskID, err := strconv.Atoi(c.Param("taskId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return nil, false
+	}
+
+	var task models.Task
+	if err := config.DB.First(&task, taskID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return nil, false
+	}
+
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && task.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view files from your own tasks"})
+		return nil, false
+	}
+	return &task, true
+}
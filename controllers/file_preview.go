@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/storage"
+	"github.com/gin-gonic/gin"
+)
+
+// GetFileThumbnail serves a JPEG thumbnail of an image attachment. ?size=
+// selects "small" (the default) or "medium".
+func GetFileThumbnail(c *gin.Context) {
+	file, ok := loadDownloadableFile(c)
+	if !ok {
+		return
+	}
+
+	if !files.IsImage(file.FileType) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "No thumbnail is available for this file type"})
+		return
+	}
+	size := c.DefaultQuery("size", "small")
+	if _, ok := files.ThumbnailSizes[size]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid thumbnail size"})
+		return
+	}
+	if !previewReady(c, file, models.PreviewImage) {
+		return
+	}
+
+	servePreview(c, files.ThumbnailKey(file, size), "image/jpeg", 0)
+}
+
+// GetFilePreview serves a preview of an attachment: the start of a text file
+// as plain text, or the medium thumbnail of an image. X-Preview-Truncated is
+// set when a text preview does not cover the whole file.
+func GetFilePreview(c *gin.Context) {
+	file, ok := loadDownloadableFile(c)
+	if !ok {
+		return
+	}
+
+	switch {
+	case files.IsImage(file.FileType):
+		if previewReady(c, file, models.PreviewImage) {
+			servePreview(c, files.ThumbnailKey(file, "medium"), "image/jpeg", 0)
+		}
+	case files.IsText(file.FileType):
+		if previewReady(c, file, models.PreviewText) {
+			servePreview(c, files.TextPreviewKey(file), "text/plain; charset=utf-8", file.FileSize)
+		}
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "No preview is available for this file type"})
+	}
+}
+
+// previewReady responds with an error unless the file has a preview of kind
+func previewReady(c *gin.Context, file *models.File, kind string) bool {
+	switch file.Preview {
+	case kind:
+		return true
+	case "":
+		c.JSON(http.StatusConflict, gin.H{"error": "Preview is still being generated"})
+	default:
+		c.JSON(http.StatusNotFound, gin.H{"error": "No preview is available for this file"})
+	}
+	return false
+}
+
+// servePreview streams a stored preview. For text previews fullSize is the
+// size of the whole file, to report whether the preview is truncated.
+func servePreview(c *gin.Context, key, contentType string, fullSize int64) {
+	reader, object, err := storage.Default.Get(c.Request.Context(), key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Preview not found"})
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to read preview %s: %v", key, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read preview from storage"})
+		return
+	}
+	defer reader.Close()
+
+	headers := map[string]string{"Cache-Control": "private, max-age=3600"}
+	if fullSize > 0 {
+		headers["X-Preview-Truncated"] = strconv.FormatBool(object.Size < fullSize)
+	}
+	c.DataFromReader(http.StatusOK, object.Size, contentType, reader, headers)
+}
@@ -0,0 +1,57 @@
+package controllers
+
+import (
+	"time"
+
+	"github.com/Chamanthra/TaskManager/models"
+)
+
+// fileResponse is a file as shown to users, without its storage details
+type fileResponse struct {
+	ID         uint       `json:"id"`
+	FileName   string     `json:"file_name"`
+	FileType   string     `json:"file_type"`
+	FileSize   int64      `json:"file_size"`
+	TaskID     uint       `json:"task_id"`
+	OriginID   uint       `json:"origin_id"`
+	Version    int        `json:"version"`
+	IsCurrent  bool       `json:"is_current"`
+	Preview    string     `json:"preview,omitempty"`
+	ScanStatus string     `json:"scan_status"`
+	ScannedAt  *time.Time `json:"scanned_at,omitempty"`
+	UploadedAt time.Time  `json:"uploaded_at"`
+	Uploader   uploader   `json:"uploader"`
+}
+
+// uploader is the public part of the user who uploaded a file. The user name
+// is only set when the user was loaded with files.UploaderColumns.
+type uploader struct {
+	ID       uint   `json:"id"`
+	UserName string `json:"user_name,omitempty"`
+}
+
+func newFileResponse(file *models.File) fileResponse {
+	return fileResponse{
+		ID:         file.ID,
+		FileName:   file.FileName,
+		FileType:   file.FileType,
+		FileSize:   file.FileSize,
+		TaskID:     file.TaskID,
+		OriginID:   file.OriginID,
+		Version:    file.Version,
+		IsCurrent:  file.IsCurrent,
+		Preview:    file.Preview,
+		ScanStatus: file.ScanStatus,
+		ScannedAt:  file.ScannedAt,
+		UploadedAt: file.UploadedAt,
+		Uploader:   uploader{ID: file.UserID, UserName: file.User.UserName},
+	}
+}
+
+func newFileResponses(files []models.File) []fileResponse {
+	responses := make([]fileResponse, len(files))
+	for i := range files {
+		responses[i] = newFileResponse(&files[i])
+	}
+	return responses
+}
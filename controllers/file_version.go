@@ -0,0 +1,139 @@
+package controllers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/events"
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/Chamanthra/TaskManager/middlewares"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+)
+
+// UploadFileVersion uploads a new version of an existing file, which becomes
+// the current version
+func UploadFileVersion(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	file, ok := loadFile(c)
+	if !ok {
+		return
+	}
+
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && file.Task.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only upload files to your own tasks"})
+		return
+	}
+
+	upload, ok := spoolFormFile(c)
+	if !ok {
+		return
+	}
+	defer upload.Close()
+
+	version, err := saveUpload(c.Request.Context(), &file.Task, userID, file.OriginID, upload)
+	if errors.Is(err, files.ErrQuotaExceeded) {
+		respondUploadError(c, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to save new version of file %d: %v", file.OriginID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newFileResponse(version))
+}
+
+// GetFileVersions lists every version of a file, newest first
+func GetFileVersions(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	file, ok := loadFile(c)
+	if !ok {
+		return
+	}
+
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && file.Task.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only view files from your own tasks"})
+		return
+	}
+
+	versions, err := files.Versions(config.DB, file.OriginID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch versions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, newFileResponses(versions))
+}
+
+// RestoreFileVersion makes an older version current by copying it into a new
+// version
+func RestoreFileVersion(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	file, ok := loadFile(c)
+	if !ok {
+		return
+	}
+
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && file.Task.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only restore files on your own tasks"})
+		return
+	}
+
+	number, err := strconv.Atoi(c.Param("version"))
+	if err != nil || number < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid version"})
+		return
+	}
+	old, err := files.Version(config.DB, file.OriginID, number)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Version not found"})
+		return
+	}
+	if old.IsCurrent {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Version is already current"})
+		return
+	}
+
+	var restored *models.File
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if restored, err = files.Restore(tx, old, userID); err != nil {
+			return err
+		}
+		return events.Record(tx, events.Event{Type: events.FileRestored, TaskID: file.TaskID, OwnerID: file.Task.UserID, ActorID: userID, Data: newFileResponse(restored)})
+	})
+	if errors.Is(err, files.ErrQuarantined) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This version is infected and cannot be restored"})
+		return
+	}
+	if errors.Is(err, files.ErrQuotaExceeded) {
+		respondUploadError(c, err)
+		return
+	}
+	if errors.Is(err, files.ErrNotRestorable) {
+		c.JSON(http.StatusConflict, gin.H{"error": "This version was stored before versioning and cannot be restored"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to restore version"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, newFileResponse(restored))
+}
@@ -0,0 +1,91 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/queue"
+	"github.com/Chamanthra/TaskManager/workers"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetScheduledJobs lists background jobs with their last and next run (requires jobs.manage)
+func GetScheduledJobs(c *gin.Context) {
+	jobs, err := workers.DefaultScheduler.Jobs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get jobs", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, jobs)
+}
+
+// RunScheduledJob starts a background job immediately (requires jobs.manage)
+func RunScheduledJob(c *gin.Context) {
+	err := workers.DefaultScheduler.RunNow(c.Param("name"))
+	switch {
+	case errors.Is(err, workers.ErrUnknownJob):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, workers.ErrJobRunning):
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is already running"})
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start job", "details": err.Error()})
+	default:
+		c.JSON(http.StatusAccepted, gin.H{"message": "Job started"})
+	}
+}
+
+// GetQueuedJobs lists queue jobs, optionally filtered by status and type (requires jobs.manage)
+func GetQueuedJobs(c *gin.Context) {
+	query := config.DB.Model(&models.QueuedJob{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if jobType := c.Query("type"); jobType != "" {
+		query = query.Where("type = ?", jobType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get queued jobs"})
+		return
+	}
+
+	page, pageSize, offset := pagination(c)
+	var jobs []models.QueuedJob
+	if err := query.Order("id desc").Offset(offset).Limit(pageSize).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get queued jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"jobs":      jobs,
+		"page":      page,
+		"page_size": pageSize,
+		"total":     total,
+	})
+}
+
+// RetryQueuedJob puts a failed queue job back in the queue (requires jobs.manage)
+func RetryQueuedJob(c *gin.Context) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid job ID"})
+		return
+	}
+
+	if err := queue.Retry(uint(id)); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Failed job not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job", "details": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Job queued for retry"})
+}
@@ -0,0 +1,186 @@
+package controllers
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/middlewares"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/realtime"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	wsWriteTimeout = 10 * time.Second
+	wsPongTimeout  = 60 * time.Second
+	wsPingInterval = 45 * time.Second
+)
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+	// Authentication uses a bearer token rather than cookies, so a
+	// cross-origin page cannot ride on the user's session
+	CheckOrigin: func(r *http.Request) bool { return true },
+}
+
+// liveRequest is a subscribe/unsubscribe message sent by the client
+type liveRequest struct {
+	Action  string `json:"action"`  // "subscribe" or "unsubscribe"
+	Channel string `json:"channel"` // "task", "project" or "my_tasks"
+	ID      uint   `json:"id"`
+}
+
+// liveResponse is a message sent to the client
+type liveResponse struct {
+	Type    string          `json:"type"` // "subscribed", "unsubscribed", "event" or "error"
+	Channel string          `json:"channel,omitempty"`
+	Event   string          `json:"event,omitempty"`
+	Data    json.RawMessage `json:"data,omitempty"`
+	Error   string          `json:"error,omitempty"`
+}
+
+// LiveUpdates upgrades the request to a WebSocket over which the client can
+// subscribe to create/update/delete events for a task or for its own task list
+func LiveUpdates(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+	sessionID, _ := claims["sid"].(string)
+
+	conn, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		return // Upgrade has already written an error response
+	}
+	defer conn.Close()
+
+	// Close the socket when the session that opened it is logged out
+	revoked, stopWatching := realtime.WatchSession(userID, sessionID)
+	defer stopWatching()
+
+	outgoing := make(chan liveResponse, 64)
+	done := make(chan struct{})
+	defer close(done)
+
+	var mu sync.Mutex
+	subscriptions := make(map[string]func())
+	defer func() {
+		mu.Lock()
+		for _, unsubscribe := range subscriptions {
+			unsubscribe()
+		}
+		mu.Unlock()
+	}()
+
+	send := func(resp liveResponse) {
+		select {
+		case outgoing <- resp:
+		case <-done:
+		}
+	}
+
+	go func() {
+		ticker := time.NewTicker(wsPingInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-revoked:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "Session revoked"))
+				conn.Close()
+				return
+			case resp := <-outgoing:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteJSON(resp); err != nil {
+					conn.Close()
+					return
+				}
+			case <-ticker.C:
+				conn.SetWriteDeadline(time.Now().Add(wsWriteTimeout))
+				if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+					conn.Close()
+					return
+				}
+			}
+		}
+	}()
+
+	conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongTimeout))
+	})
+
+	for {
+		var req liveRequest
+		if err := conn.ReadJSON(&req); err != nil {
+			return
+		}
+
+		topic, errMsg := authorizeLiveChannel(req, userID, middlewares.Can(c, models.PermissionAllTasks))
+		if errMsg != "" {
+			send(liveResponse{Type: "error", Error: errMsg})
+			continue
+		}
+
+		mu.Lock()
+		switch req.Action {
+		case "subscribe":
+			if _, exists := subscriptions[topic]; !exists {
+				messages, unsubscribe := realtime.DefaultBroker.Subscribe(topic)
+				subscriptions[topic] = unsubscribe
+				go func() {
+					for msg := range messages {
+						send(liveResponse{Type: "event", Channel: msg.Topic, Event: msg.Event, Data: msg.Data})
+					}
+				}()
+			}
+			mu.Unlock()
+			send(liveResponse{Type: "subscribed", Channel: topic})
+		case "unsubscribe":
+			if unsubscribe, exists := subscriptions[topic]; exists {
+				unsubscribe()
+				delete(subscriptions, topic)
+			}
+			mu.Unlock()
+			send(liveResponse{Type: "unsubscribed", Channel: topic})
+		default:
+			mu.Unlock()
+			send(liveResponse{Type: "error", Error: "Unknown action"})
+		}
+	}
+}
+
+// authorizeLiveChannel resolves a subscription request to a broker topic,
+// applying the same ownership rules as the REST endpoints
+func authorizeLiveChannel(req liveRequest, userID uint, allTasks bool) (string, string) {
+	switch req.Channel {
+	case "task":
+		var task models.Task
+		if err := config.DB.First(&task, req.ID).Error; err != nil {
+			return "", "Task not found"
+		}
+		if !allTasks && task.UserID != userID {
+			return "", "You can only subscribe to your own tasks"
+		}
+		return realtime.TaskTopic(task.ID), ""
+	case "my_tasks":
+		// Users who can act on all tasks may watch another user's task list by passing its ID
+		if req.ID != 0 && req.ID != userID {
+			if !allTasks {
+				return "", "You can only subscribe to your own task list"
+			}
+			return realtime.UserTasksTopic(req.ID), ""
+		}
+		return realtime.UserTasksTopic(userID), ""
+	case "project":
+		return "", "Projects are not supported yet"
+	default:
+		return "", "Unknown channel"
+	}
+}
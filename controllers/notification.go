@@ -1,26 +1,137 @@
 package controllers
 
 import (
+	"encoding/json"
+	"io"
 	"net/http"
+	"slices"
+	"strconv"
+	"time"
 
 	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/middlewares"
 	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/notifications"
+	"github.com/Chamanthra/TaskManager/realtime"
+	"github.com/gin-contrib/sse"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-// GetUserNotifications gets all notifications for the current user
+// GetUserNotifications gets a page of the current user's notifications,
+// optionally filtered by type, status and task
 func GetUserNotifications(c *gin.Context) {
 	claims := c.MustGet("claims").(jwt.MapClaims)
 	userID := uint(claims["user_id"].(float64))
 
-	var notifications []models.Notification
-	if err := config.DB.Where("user_id = ?", userID).Order("created_at desc").Find(&notifications).Error; err != nil {
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ?", userID)
+	if notificationType := c.Query("type"); notificationType != "" {
+		query = query.Where("type = ?", notificationType)
+	}
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if taskID := c.Query("task_id"); taskID != "" {
+		query = query.Where("task_id = ?", taskID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+		return
+	}
+
+	page, pageSize, offset := pagination(c)
+	var userNotifications []models.Notification
+	if err := query.Order("created_at desc").Offset(offset).Limit(pageSize).Find(&userNotifications).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
 		return
 	}
 
-	c.JSON(http.StatusOK, notifications)
+	c.JSON(http.StatusOK, gin.H{
+		"notifications": userNotifications,
+		"page":          page,
+		"page_size":     pageSize,
+		"total":         total,
+	})
+}
+
+// GetUnreadNotificationCount returns how many unread notifications the current
+// user has. It is a single indexed count, cheap enough to poll.
+func GetUnreadNotificationCount(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	var unread int64
+	if err := config.DB.Model(&models.Notification{}).Where("user_id = ? AND status = ?", userID, "unread").Count(&unread).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"unread": unread})
+}
+
+// MarkAllNotificationsAsRead marks every unread notification of the current
+// user as read, or only those for one task when task_id is given
+func MarkAllNotificationsAsRead(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	query := config.DB.Model(&models.Notification{}).Where("user_id = ? AND status = ?", userID, "unread")
+	if taskID := c.Query("task_id"); taskID != "" {
+		id, err := strconv.Atoi(taskID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+		query = query.Where("task_id = ?", id)
+	}
+
+	result := query.Update("status", "read")
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications marked as read", "updated": result.RowsAffected})
+}
+
+// DeleteNotifications deletes several of the current user's notifications at
+// once, either by ID or every notification with a given status
+func DeleteNotifications(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	var input struct {
+		IDs    []uint `json:"ids"`
+		Status string `json:"status"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format", "details": err.Error()})
+		return
+	}
+	if len(input.IDs) == 0 && input.Status == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Provide notification ids or a status"})
+		return
+	}
+
+	query := config.DB.Where("user_id = ?", userID)
+	if len(input.IDs) > 0 {
+		query = query.Where("id IN ?", input.IDs)
+	}
+	if input.Status != "" {
+		query = query.Where("status = ?", input.Status)
+	}
+
+	result := query.Delete(&models.Notification{})
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete notifications"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Notifications deleted", "deleted": result.RowsAffected})
 }
 
 // MarkNotificationAsRead marks a notification as read
@@ -49,3 +160,261 @@ func MarkNotificationAsRead(c *gin.Context) {
 
 	c.JSON(http.StatusOK, notification)
 }
+
+// StreamNotifications pushes new notifications to the client as Server-Sent Events.
+// Clients reconnecting with a Last-Event-ID header (or last_event_id query
+// parameter) first receive every notification they missed.
+func StreamNotifications(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+	var lastID uint64
+	if lastEventID != "" {
+		id, err := strconv.ParseUint(lastEventID, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+		lastID = id
+	}
+
+	// Subscribe before replaying so nothing created in between is lost
+	messages, unsubscribe := realtime.DefaultBroker.Subscribe(realtime.NotificationTopic(userID))
+	defer unsubscribe()
+	sessionID, _ := claims["sid"].(string)
+	revoked, stopWatching := realtime.WatchSession(userID, sessionID)
+	defer stopWatching()
+
+	var missed []models.Notification
+	if lastID > 0 {
+		if err := config.DB.Where("user_id = ? AND id > ?", userID, lastID).Order("id asc").Find(&missed).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get notifications"})
+			return
+		}
+	}
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+
+	for _, notification := range missed {
+		c.Render(-1, sse.Event{
+			Id:    strconv.FormatUint(uint64(notification.ID), 10),
+			Event: "notification",
+			Data:  notification,
+		})
+		lastID = uint64(notification.ID)
+	}
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(30 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-revoked:
+			return false // The session was logged out
+		case <-heartbeat.C:
+			// Comment lines keep proxies from closing an idle connection
+			io.WriteString(w, ": ping\n\n")
+			return true
+		case msg, ok := <-messages:
+			if !ok {
+				return false
+			}
+			// Skip anything already sent during the replay
+			if id, err := strconv.ParseUint(msg.ID, 10, 64); err == nil && id <= lastID {
+				return true
+			}
+			c.Render(-1, sse.Event{
+				Id:    msg.ID,
+				Event: msg.Event,
+				Data:  json.RawMessage(msg.Data),
+			})
+			return true
+		}
+	})
+}
+
+// GetNotificationPreferences returns the current user's channel per
+// notification type, quiet hours and muted tasks
+func GetNotificationPreferences(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	var prefs []models.NotificationPreference
+	if err := config.DB.Where("user_id = ?", userID).Find(&prefs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get preferences"})
+		return
+	}
+
+	channels := make(map[string]string)
+	for _, notificationType := range models.NotificationTypes {
+		channels[notificationType] = models.ChannelInApp
+	}
+	for _, pref := range prefs {
+		channels[pref.Type] = pref.Channel
+	}
+
+	settings := models.NotificationSettings{UserID: userID, TimeZone: "UTC", EmailDigest: models.DigestOff}
+	config.DB.Where("user_id = ?", userID).Limit(1).Find(&settings)
+
+	var mutes []models.TaskMute
+	if err := config.DB.Where("user_id = ?", userID).Find(&mutes).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get muted tasks"})
+		return
+	}
+	mutedTasks := make([]uint, 0, len(mutes))
+	for _, mute := range mutes {
+		mutedTasks = append(mutedTasks, mute.TaskID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"channels":    channels,
+		"settings":    settings,
+		"muted_tasks": mutedTasks,
+	})
+}
+
+// UpdateNotificationPreferences changes the current user's channels, quiet
+// hours and email digest mode
+func UpdateNotificationPreferences(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	var input struct {
+		Channels map[string]string            `json:"channels"`
+		Settings *models.NotificationSettings `json:"settings"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format", "details": err.Error()})
+		return
+	}
+
+	for notificationType, channel := range input.Channels {
+		if !slices.Contains(models.NotificationTypes, notificationType) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown notification type: " + notificationType})
+			return
+		}
+		if !slices.Contains(models.NotificationChannels, channel) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown channel: " + channel})
+			return
+		}
+	}
+
+	if input.Settings != nil {
+		if (input.Settings.QuietHoursStart == "") != (input.Settings.QuietHoursEnd == "") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Quiet hours need both a start and an end"})
+			return
+		}
+		for _, value := range []string{input.Settings.QuietHoursStart, input.Settings.QuietHoursEnd} {
+			if value == "" {
+				continue
+			}
+			if _, err := notifications.ParseClock(value); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if input.Settings.TimeZone == "" {
+			input.Settings.TimeZone = "UTC"
+		}
+		if _, err := time.LoadLocation(input.Settings.TimeZone); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone: " + input.Settings.TimeZone})
+			return
+		}
+		if input.Settings.EmailDigest == "" {
+			input.Settings.EmailDigest = models.DigestOff
+		}
+		if !slices.Contains(models.DigestModes, input.Settings.EmailDigest) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown email digest mode: " + input.Settings.EmailDigest})
+			return
+		}
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		for notificationType, channel := range input.Channels {
+			pref := models.NotificationPreference{UserID: userID, Type: notificationType, Channel: channel}
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}, {Name: "type"}},
+				DoUpdates: clause.AssignmentColumns([]string{"channel"}),
+			}).Create(&pref).Error; err != nil {
+				return err
+			}
+		}
+
+		if input.Settings != nil {
+			input.Settings.UserID = userID
+			if err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "user_id"}},
+				DoUpdates: clause.AssignmentColumns([]string{"quiet_hours_start", "quiet_hours_end", "time_zone", "email_digest"}),
+			}).Create(input.Settings).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update preferences", "details": err.Error()})
+		return
+	}
+
+	GetNotificationPreferences(c)
+}
+
+// MuteTask stops all notifications about a task for the current user
+func MuteTask(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	taskID, err := strconv.Atoi(c.Param("taskId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	var task models.Task
+	if err := config.DB.First(&task, taskID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+		return
+	}
+
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && task.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only mute your own tasks"})
+		return
+	}
+
+	mute := models.TaskMute{UserID: userID, TaskID: task.ID}
+	if err := config.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&mute).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to mute task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task muted"})
+}
+
+// UnmuteTask resumes notifications about a task for the current user
+func UnmuteTask(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	taskID, err := strconv.Atoi(c.Param("taskId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+		return
+	}
+
+	if err := config.DB.Where("user_id = ? AND task_id = ?", userID, taskID).Delete(&models.TaskMute{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unmute task"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Task unmuted"})
+}
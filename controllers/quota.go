@@ -0,0 +1,79 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// GetStorageQuotas lists every user's storage use and quota (requires users.manage)
+func GetStorageQuotas(c *gin.Context) {
+	var users []models.User
+	if err := config.DB.Order("id").Find(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage quotas"})
+		return
+	}
+	usage, err := files.UsageByUser(config.DB)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage quotas"})
+		return
+	}
+
+	quotas := make([]gin.H, 0, len(users))
+	for _, user := range users {
+		quotas = append(quotas, gin.H{
+			"user_id":       user.ID,
+			"username":      user.UserName,
+			"used":          usage[user.ID],
+			"quota":         files.QuotaOf(&user),
+			"storage_quota": user.StorageQuota,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"default_quota": files.DefaultQuota(), "users": quotas})
+}
+
+// UpdateStorageQuota sets a user's storage quota in bytes (requires users.manage). A
+// quota of zero means unlimited and null restores the default.
+func UpdateStorageQuota(c *gin.Context) {
+	var input struct {
+		Quota *int64 `json:"quota"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if input.Quota != nil && *input.Quota < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Quota cannot be negative"})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, c.Param("id")).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+		}
+		return
+	}
+
+	// Lowering a quota below current use only blocks further uploads
+	if err := config.DB.Model(&user).Update("storage_quota", input.Quota).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update storage quota"})
+		return
+	}
+	user.StorageQuota = input.Quota
+
+	usage, err := files.UsageOf(config.DB, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get storage usage"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"user_id": user.ID, "storage_quota": user.StorageQuota, "storage": usage})
+}
@@ -0,0 +1,372 @@
+package controllers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/realtime"
+	"github.com/Chamanthra/TaskManager/sessions"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Changes to a role's flags and permissions apply to its users' next request,
+// since AuthMiddleware loads the role every time. Moving a user to another
+// role also revokes their tokens.
+
+type roleInput struct {
+	Role              string   `json:"role"`
+	Name              string   `json:"name"`
+	Description       *string  `json:"description"`
+	CanManageTasks    *bool    `json:"can_manage_tasks"`
+	CanManageUsers    *bool    `json:"can_manage_users"`
+	CanManageComments *bool    `json:"can_manage_comments"`
+	CanManageFiles    *bool    `json:"can_manage_files"`
+	IsAdmin           *bool    `json:"is_admin"`
+	Permissions       []string `json:"permissions"` // Names; omit to leave unchanged
+}
+
+var errLastAdmin = errors.New("at least one active user must keep an admin role")
+
+// GetRoles lists the roles with their permissions (admins only)
+func GetRoles(c *gin.Context) {
+	var roles []models.Role
+	if err := config.DB.Preload("Permissions").Order("id").Find(&roles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get roles"})
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+// CreateRole adds a role (admins only)
+func CreateRole(c *gin.Context) {
+	var input roleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format", "details": err.Error()})
+		return
+	}
+
+	var role models.Role
+	if errMsg := applyRoleInput(&role, input, true); errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	var existing models.Role
+	if err := config.DB.Where("role = ?", role.Role).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role already exists"})
+		return
+	}
+
+	if err := config.DB.Create(&role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create role", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole changes a role's details, flags and permissions (admins
+// only)
+func UpdateRole(c *gin.Context) {
+	role, ok := loadRole(c)
+	if !ok {
+		return
+	}
+	wasAdmin := role.IsAdmin
+
+	var input roleInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format", "details": err.Error()})
+		return
+	}
+	if input.Role != "" && input.Role != role.Role {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "A role's key cannot be changed"})
+		return
+	}
+	if errMsg := applyRoleInput(role, input, false); errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if wasAdmin && !role.IsAdmin {
+			if err := checkAdminsRemain(tx, "roles.id <> ?", role.ID); err != nil {
+				return err
+			}
+		}
+		if err := tx.Omit("Permissions").Save(role).Error; err != nil {
+			return err
+		}
+		if input.Permissions == nil {
+			return nil
+		}
+		return tx.Model(role).Association("Permissions").Replace(role.Permissions)
+	})
+	if errors.Is(err, errLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The last admin role cannot lose admin rights"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update role", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole removes a role that no user has (admins only)
+func DeleteRole(c *gin.Context) {
+	role, ok := loadRole(c)
+	if !ok {
+		return
+	}
+	if role.Role == models.DefaultRole {
+		c.JSON(http.StatusConflict, gin.H{"error": "The default role cannot be deleted"})
+		return
+	}
+
+	var users int64
+	if err := config.DB.Model(&models.User{}).Where("role_id = ?", role.ID).Count(&users).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+	if users > 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": "Role is still assigned to users", "users": users})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Permissions").Clear(); err != nil {
+			return err
+		}
+		return tx.Delete(role).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete role"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Role deleted"})
+}
+
+// UpdateUserRole moves a user to another role and revokes their tokens, so
+// they must log in again (admins only)
+func UpdateUserRole(c *gin.Context) {
+	var input struct {
+		RoleID uint `json:"role_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format", "details": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := config.DB.Preload("Role").First(&user, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
+		return
+	}
+	var role models.Role
+	if err := config.DB.First(&role, input.RoleID).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid role ID"})
+		return
+	}
+	if role.ID == user.RoleID {
+		c.JSON(http.StatusOK, gin.H{"message": "User already has this role"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if user.Role.IsAdmin && !role.IsAdmin {
+			if err := checkAdminsRemain(tx, "users.id <> ?", user.ID); err != nil {
+				return err
+			}
+		}
+		err := tx.Model(&user).Updates(map[string]interface{}{
+			"role_id":       role.ID,
+			"token_version": gorm.Expr("token_version + 1"),
+		}).Error
+		if err != nil {
+			return err
+		}
+		return sessions.RevokeRefreshTokens(tx, user.ID)
+	})
+	if errors.Is(err, errLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The last admin cannot be given a non-admin role"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update user role", "details": err.Error()})
+		return
+	}
+	realtime.PublishSessionRevoked(user.ID, "")
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "User role updated",
+		"user": gin.H{
+			"id":       user.ID,
+			"username": user.UserName,
+			"role":     role.Role,
+		},
+	})
+}
+
+// GetPermissions lists the permissions that can be granted to roles
+// (admins only)
+func GetPermissions(c *gin.Context) {
+	var permissions []models.Permission
+	if err := config.DB.Order("name").Find(&permissions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get permissions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, permissions)
+}
+
+// CreatePermission adds a custom permission for use by integrations
+// (admins only)
+func CreatePermission(c *gin.Context) {
+	var input struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+	}
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format", "details": err.Error()})
+		return
+	}
+
+	permission := models.Permission{Name: strings.TrimSpace(input.Name), Description: input.Description}
+	if permission.Name == "" || strings.ContainsAny(permission.Name, " \t\n") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Permission names must not be empty or contain spaces"})
+		return
+	}
+
+	var existing models.Permission
+	if err := config.DB.Where("name = ?", permission.Name).First(&existing).Error; err == nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Permission already exists"})
+		return
+	}
+	if err := config.DB.Create(&permission).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create permission", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, permission)
+}
+
+// DeletePermission removes a custom permission from every role (admins
+// only). Built-in permissions cannot be deleted.
+func DeletePermission(c *gin.Context) {
+	var permission models.Permission
+	if err := config.DB.First(&permission, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Permission not found"})
+		return
+	}
+	if _, builtIn := models.Permissions[permission.Name]; builtIn {
+		c.JSON(http.StatusConflict, gin.H{"error": "Built-in permissions cannot be deleted"})
+		return
+	}
+
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM role_permissions WHERE permission_id = ?", permission.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&permission).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete permission"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Permission deleted"})
+}
+
+// applyRoleInput copies the fields set in input to the role, resolving
+// permission names. It returns a message describing invalid input.
+func applyRoleInput(role *models.Role, input roleInput, creating bool) string {
+	if creating {
+		role.Role = strings.TrimSpace(input.Role)
+		if role.Role == "" {
+			return "Role key is required"
+		}
+	}
+	if input.Name != "" {
+		role.Name = input.Name
+	} else if creating {
+		role.Name = role.Role
+	}
+	if input.Description != nil {
+		role.Description = *input.Description
+	}
+
+	setFlag(&role.CanManageTasks, input.CanManageTasks)
+	setFlag(&role.CanManageUsers, input.CanManageUsers)
+	setFlag(&role.CanManageComments, input.CanManageComments)
+	setFlag(&role.CanManageFiles, input.CanManageFiles)
+	setFlag(&role.IsAdmin, input.IsAdmin)
+
+	if input.Permissions != nil {
+		role.Permissions = []models.Permission{}
+		if len(input.Permissions) > 0 {
+			if err := config.DB.Where("name IN ?", input.Permissions).Find(&role.Permissions).Error; err != nil {
+				return "Failed to look up permissions"
+			}
+		}
+		if len(role.Permissions) != len(input.Permissions) {
+			return "Permissions must list existing permission names"
+		}
+	}
+	return ""
+}
+
+func setFlag(flag *bool, value *bool) {
+	if value != nil {
+		*flag = *value
+	}
+}
+
+// loadRole loads the role named by the id parameter with its permissions,
+// responding with an error if it does not exist
+func loadRole(c *gin.Context) (*models.Role, bool) {
+	var role models.Role
+	if err := config.DB.Preload("Permissions").First(&role, c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Role not found"})
+		return nil, false
+	}
+	return &role, true
+}
+
+// checkAdminsRemain returns errLastAdmin unless an active user with an admin
+// role matches the condition, which excludes the user or role being changed.
+// The admins and their roles are locked until tx ends, so concurrent changes
+// to the last admins are checked one after the other.
+func checkAdminsRemain(tx *gorm.DB, query string, args ...interface{}) error {
+	var locked []uint
+	err := tx.Model(&models.User{}).
+		Joins("JOIN roles ON roles.id = users.role_id").
+		Where("roles.is_admin AND users.is_active").
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Pluck("users.id", &locked).Error
+	if err != nil {
+		return err
+	}
+
+	var admins int64
+	err = tx.Model(&models.User{}).
+		Joins("JOIN roles ON roles.id = users.role_id").
+		Where("roles.is_admin AND users.is_active").
+		Where(query, args...).
+		Count(&admins).Error
+	if err != nil {
+		return err
+	}
+	if admins == 0 {
+		return errLastAdmin
+	}
+	return nil
+}
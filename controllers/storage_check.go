@@ -0,0 +1,28 @@
+package controllers
+
+import (
+	"log"
+	"net/http"
+
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/gin-gonic/gin"
+)
+
+// CheckStorage compares the file records with the objects in storage and
+// returns what is inconsistent (requires storage.manage). ?repair=true also
+// fixes it.
+func CheckStorage(c *gin.Context) {
+	report, err := files.CheckStorage(c.Request.Context(), c.Query("repair") == "true")
+	if report == nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage", "details": err.Error()})
+		return
+	}
+	if err != nil {
+		// Some repairs failed; the report still says what was found
+		log.Printf("Storage repair failed: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Some repairs failed", "details": err.Error(), "report": report})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
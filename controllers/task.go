@@ -1,12 +1,16 @@
 package controllers
 
 import (
+	"log"
 	"net/http"
 
 	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/events"
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/Chamanthra/TaskManager/middlewares"
 	"github.com/Chamanthra/TaskManager/models"
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v4"
+	"github.com/golang-jwt/jwt/v5"
 	"gorm.io/gorm"
 )
 
@@ -31,7 +35,13 @@ func CreateTask(c *gin.Context) {
 	// Set the user ID for the task
 	task.UserID = userID
 
-	if err := config.DB.Create(&task).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&task).Error; err != nil {
+			return err
+		}
+		return events.Record(tx, events.Event{Type: events.TaskCreated, TaskID: task.ID, OwnerID: task.UserID, ActorID: userID, Data: task})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create task", "details": err.Error()})
 		return
 	}
@@ -49,12 +59,11 @@ func GetTasks(c *gin.Context) {
 
 	jwtClaims := claims.(jwt.MapClaims)
 	userID := uint(jwtClaims["user_id"].(float64))
-	role := jwtClaims["role"].(string)
 
 	var tasks []models.Task
 	var query *gorm.DB
 
-	if role == "admin" {
+	if middlewares.Can(c, models.PermissionAllTasks) {
 		// Admin can see all tasks
 		query = config.DB.Preload("User") // Include user information
 	} else {
@@ -80,9 +89,8 @@ func UpdateTask(c *gin.Context) {
 
 	jwtClaims := claims.(jwt.MapClaims)
 	userID := uint(jwtClaims["user_id"].(float64))
-	role := jwtClaims["role"].(string)
 
-	id := c.Param("id")
+	id := c.Param("taskId")
 	var task models.Task
 
 	if err := config.DB.First(&task, id).Error; err != nil {
@@ -90,12 +98,14 @@ func UpdateTask(c *gin.Context) {
 		return
 	}
 
-	// Check ownership (unless admin)
-	if role != "admin" && task.UserID != userID {
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && task.UserID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only update your own tasks"})
 		return
 	}
 
+	previousDueDate := task.DueDate
+
 	if err := c.ShouldBindJSON(&task); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -104,7 +114,21 @@ func UpdateTask(c *gin.Context) {
 	// Ensure user can't change ownership
 	task.UserID = userID
 
-	if err := config.DB.Save(&task).Error; err != nil {
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&task).Error; err != nil {
+			return err
+		}
+
+		// A new due date restarts the reminder schedule
+		if !task.DueDate.Equal(previousDueDate) {
+			if err := tx.Where("task_id = ?", task.ID).Delete(&models.TaskReminder{}).Error; err != nil {
+				return err
+			}
+		}
+
+		return events.Record(tx, events.Event{Type: events.TaskUpdated, TaskID: task.ID, OwnerID: task.UserID, ActorID: userID, Data: task})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update task", "details": err.Error()})
 		return
 	}
@@ -122,9 +146,8 @@ func DeleteTask(c *gin.Context) {
 
 	jwtClaims := claims.(jwt.MapClaims)
 	userID := uint(jwtClaims["user_id"].(float64))
-	role := jwtClaims["role"].(string)
 
-	id := c.Param("id")
+	id := c.Param("taskId")
 	var task models.Task
 
 	if err := config.DB.First(&task, id).Error; err != nil {
@@ -132,30 +155,39 @@ func DeleteTask(c *gin.Context) {
 		return
 	}
 
-	// Check ownership (unless admin)
-	if role != "admin" && task.UserID != userID {
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && task.UserID != userID {
 		c.JSON(http.StatusForbidden, gin.H{"error": "You can only delete your own tasks"})
 		return
 	}
 
-	if err := config.DB.Delete(&task).Error; err != nil {
+	// The task's attachments are deleted along with it
+	var taskFiles []models.File
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		if taskFiles, err = files.DetachTask(tx, task.ID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&task).Error; err != nil {
+			return err
+		}
+		return events.Record(tx, events.Event{Type: events.TaskDeleted, TaskID: task.ID, OwnerID: task.UserID, ActorID: userID, Data: task})
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete task", "details": err.Error()})
 		return
 	}
 
+	// Anything left behind by a storage failure is found by the storage check
+	if err := files.Purge(c.Request.Context(), taskFiles); err != nil {
+		log.Printf("Failed to delete files of task %d from storage: %v", task.ID, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Task deleted"})
 }
 
-// GetAllTasks gets all tasks (admin only)
+// GetAllTasks gets all tasks (requires tasks.all)
 func GetAllTasks(c *gin.Context) {
-	claims := c.MustGet("claims").(jwt.MapClaims)
-	role := claims["role"].(string)
-
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can view all tasks"})
-		return
-	}
-
 	var tasks []models.Task
 	if err := config.DB.Preload("User").Find(&tasks).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve tasks"})
@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"encoding/base64"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/Chamanthra/TaskManager/middlewares"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Resumable uploads implement the core tus 1.0.0 protocol with the creation,
+// termination and expiration extensions. See https://tus.io/protocols/resumable-upload
+
+const tusVersion = "1.0.0"
+
+// TusOptions advertises the supported tus version and extensions
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", "creation,termination,expiration")
+	c.Status(http.StatusNoContent)
+}
+
+// CreateUpload starts a resumable upload. Upload-Metadata must carry
+// task_id and filename, or file_id and filename to upload a new version of an
+// existing file.
+func CreateUpload(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	if !checkTusVersion(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Length must be a non-negative integer"})
+		return
+	}
+	if length > files.MaxFileSize() {
+		respondUploadError(c, files.ErrFileTooLarge)
+		return
+	}
+
+	metadata, err := parseUploadMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Upload-Metadata"})
+		return
+	}
+
+	upload := models.ResumableUpload{
+		UserID:    userID,
+		FileName:  files.SanitizeName(metadata["filename"]),
+		Length:    length,
+		ExpiresAt: time.Now().Add(files.UploadExpiry),
+	}
+
+	// A new version of an existing file, or a new file on a task
+	var task models.Task
+	if value, ok := metadata["file_id"]; ok {
+		fileID, err := strconv.ParseUint(value, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid file ID"})
+			return
+		}
+		var file models.File
+		if err := config.DB.Preload("Task").First(&file, fileID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "File not found"})
+			return
+		}
+		upload.OriginID = file.OriginID
+		task = file.Task
+	} else {
+		taskID, err := strconv.ParseUint(metadata["task_id"], 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid task ID"})
+			return
+		}
+		if err := config.DB.First(&task, taskID).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Task not found"})
+			return
+		}
+	}
+	upload.TaskID = task.ID
+
+	// Check ownership (unless the role may act on all tasks)
+	if !middlewares.Can(c, models.PermissionAllTasks) && task.UserID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"error": "You can only upload files to your own tasks"})
+		return
+	}
+
+	// Reject uploads that cannot fit before any data is sent
+	if err := files.CheckQuota(config.DB, userID, length); err != nil {
+		if errors.Is(err, files.ErrQuotaExceeded) {
+			respondUploadError(c, err)
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		}
+		return
+	}
+
+	if upload.ID, err = files.NewUploadID(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+	if err := config.DB.Create(&upload).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload"})
+		return
+	}
+
+	c.Header("Location", "/api/uploads/"+upload.ID)
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// GetUploadOffset reports how many bytes of an upload have been received
+func GetUploadOffset(c *gin.Context) {
+	upload, ok := loadUpload(c)
+	if !ok {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if upload.FileID != nil {
+		c.Header("Upload-File-Id", strconv.FormatUint(uint64(*upload.FileID), 10))
+	}
+	c.Status(http.StatusOK)
+}
+
+// PatchUpload appends a chunk to an upload. When the last byte arrives the
+// parts are combined into a file on the task.
+func PatchUpload(c *gin.Context) {
+	upload, ok := loadUpload(c)
+	if !ok {
+		return
+	}
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Content-Type must be application/offset+octet-stream"})
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Upload-Offset must be a non-negative integer"})
+		return
+	}
+
+	if upload.Offset < upload.Length {
+		body := http.MaxBytesReader(c.Writer, c.Request.Body, files.MaxRequestSize())
+		err = files.WritePart(c.Request.Context(), upload, offset, body)
+		var tooLarge *http.MaxBytesError
+		switch {
+		case errors.As(err, &tooLarge):
+			// The part received within the limit is kept; the client can
+			// resume from Upload-Offset with smaller chunks
+			c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Chunk is too large", "max_size": files.MaxRequestSize()})
+			return
+		case errors.Is(err, files.ErrOffsetMismatch):
+			c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match the current offset"})
+			return
+		case errors.Is(err, files.ErrUploadTooLarge):
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "Chunk exceeds Upload-Length"})
+			return
+		case err != nil && upload.Offset == offset:
+			// Nothing was stored
+			log.Printf("Failed to store chunk of upload %s: %v", upload.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to store chunk"})
+			return
+		}
+		// A dropped connection still stores the bytes received; the client
+		// resumes from the new offset
+	} else if offset != upload.Offset {
+		c.JSON(http.StatusConflict, gin.H{"error": "Upload-Offset does not match the current offset"})
+		return
+	}
+
+	if upload.Offset == upload.Length && upload.FileID == nil {
+		err := completeUpload(c, upload)
+		if errors.Is(err, files.ErrTypeNotAllowed) || errors.Is(err, files.ErrFileTooLarge) || errors.Is(err, files.ErrQuotaExceeded) {
+			files.DiscardUpload(c.Request.Context(), upload)
+			respondUploadError(c, err)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to complete upload %s: %v", upload.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+			return
+		}
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+	if upload.FileID != nil {
+		c.Header("Upload-File-Id", strconv.FormatUint(uint64(*upload.FileID), 10))
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteUpload abandons an upload and discards the received data
+func DeleteUpload(c *gin.Context) {
+	upload, ok := loadUpload(c)
+	if !ok {
+		return
+	}
+
+	if err := files.DiscardUpload(c.Request.Context(), upload); err != nil {
+		log.Printf("Failed to discard upload %s: %v", upload.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete upload"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// completeUpload combines the parts of a fully received upload into a file
+func completeUpload(c *gin.Context, upload *models.ResumableUpload) error {
+	var task models.Task
+	if err := config.DB.First(&task, upload.TaskID).Error; err != nil {
+		return err
+	}
+
+	parts, err := files.OpenParts(c.Request.Context(), upload)
+	if err != nil {
+		return err
+	}
+	defer parts.Close()
+
+	spooled, err := files.Spool(parts, upload.FileName)
+	if err != nil {
+		return err
+	}
+	defer spooled.Close()
+
+	file, err := saveUpload(c.Request.Context(), &task, upload.UserID, upload.OriginID, spooled)
+	if err != nil {
+		return err
+	}
+
+	// Keep the upload until it expires so the client can look up the file
+	upload.FileID = &file.ID
+	if err := config.DB.Model(upload).Update("file_id", file.ID).Error; err != nil {
+		return err
+	}
+	if err := files.DiscardParts(c.Request.Context(), upload); err != nil {
+		log.Printf("Failed to discard parts of upload %s: %v", upload.ID, err)
+	}
+	return nil
+}
+
+// loadUpload loads the caller's upload named by the id parameter, responding
+// with an error if it does not exist or has expired
+func loadUpload(c *gin.Context) (*models.ResumableUpload, bool) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	if !checkTusVersion(c) {
+		return nil, false
+	}
+
+	var upload models.ResumableUpload
+	if err := config.DB.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&upload).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Upload not found"})
+		return nil, false
+	}
+	if upload.ExpiresAt.Before(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{"error": "Upload has expired"})
+		return nil, false
+	}
+	return &upload, true
+}
+
+// checkTusVersion rejects requests from clients speaking another tus version
+func checkTusVersion(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Unsupported Tus-Resumable version"})
+		return false
+	}
+	return true
+}
+
+// parseUploadMetadata decodes the comma-separated "key base64value" pairs of
+// the Upload-Metadata header
+func parseUploadMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		key, encoded, _ := strings.Cut(strings.TrimSpace(pair), " ")
+		if key == "" {
+			return nil, errors.New("empty metadata key")
+		}
+		value, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return nil, err
+		}
+		metadata[key] = string(value)
+	}
+	return metadata, nil
+}
@@ -5,6 +5,8 @@ import (
 	"net/http"
 
 	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/Chamanthra/TaskManager/middlewares"
 	"github.com/Chamanthra/TaskManager/models"
 	"github.com/Chamanthra/TaskManager/utils"
 	"github.com/gin-gonic/gin"
@@ -12,25 +14,10 @@ import (
 	"gorm.io/gorm"
 )
 
-// GetUsers retrieves all users (admin only)
+// GetUsers retrieves all users (requires users.manage)
 func GetUsers(c *gin.Context) {
-	claims, exists := c.Get("claims")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User information not found"})
-		return
-	}
-
-	jwtClaims := claims.(jwt.MapClaims)
-	role := jwtClaims["role"].(string)
-
-	// Only allow admins to list users
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can list users"})
-		return
-	}
-
 	var users []models.User
-	if err := config.DB.Find(&users).Error; err != nil {
+	if err := config.DB.Preload("Role").Find(&users).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve users", "details": err.Error()})
 		return
 	}
@@ -41,7 +28,8 @@ func GetUsers(c *gin.Context) {
 		sanitizedUsers = append(sanitizedUsers, gin.H{
 			"id":         user.ID,
 			"username":   user.UserName,
-			"role":       user.Role,
+			"role":       user.Role.Role,
+			"role_id":    user.RoleID,
 			"created_at": user.CreatedAt,
 		})
 	}
@@ -49,28 +37,14 @@ func GetUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, sanitizedUsers)
 }
 
-// DeleteUser handles user deletion (admin only)
+// DeleteUser handles user deletion (requires users.manage, and an admin role
+// to delete an admin)
 func DeleteUser(c *gin.Context) {
-	claims, exists := c.Get("claims")
-	if !exists {
-		c.JSON(http.StatusUnauthorized, gin.H{"error": "User information not found"})
-		return
-	}
-
-	jwtClaims := claims.(jwt.MapClaims)
-	role := jwtClaims["role"].(string)
-
-	// Only allow admins to delete users
-	if role != "admin" {
-		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can delete users"})
-		return
-	}
-
 	userID := c.Param("id")
 	var user models.User
 
 	// First check if user exists
-	if err := config.DB.First(&user, userID).Error; err != nil {
+	if err := config.DB.Preload("Role").First(&user, userID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		} else {
@@ -79,8 +53,29 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
-	// Delete the user
-	if err := config.DB.Delete(&user).Error; err != nil {
+	// Deleting admins is reserved to admins, so users.manage cannot be used
+	// to remove the people who manage roles
+	if user.Role.IsAdmin {
+		if role := middlewares.CurrentRole(c); role == nil || !role.IsAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can delete admin users"})
+			return
+		}
+	}
+
+	// Delete the user, unless they are the last admin
+	err := config.DB.Transaction(func(tx *gorm.DB) error {
+		if user.Role.IsAdmin && user.IsActive {
+			if err := checkAdminsRemain(tx, "users.id <> ?", user.ID); err != nil {
+				return err
+			}
+		}
+		return tx.Delete(&user).Error
+	})
+	if errors.Is(err, errLastAdmin) {
+		c.JSON(http.StatusConflict, gin.H{"error": "The last admin cannot be deleted"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user", "details": err.Error()})
 		return
 	}
@@ -100,7 +95,7 @@ func GetUserProfile(c *gin.Context) {
 	userID := uint(jwtClaims["user_id"].(float64))
 
 	var user models.User
-	if err := config.DB.First(&user, userID).Error; err != nil {
+	if err := config.DB.Preload("Role.Permissions").First(&user, userID).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "User not found"})
 		} else {
@@ -109,12 +104,20 @@ func GetUserProfile(c *gin.Context) {
 		return
 	}
 
+	usage, err := files.UsageOf(config.DB, &user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+		return
+	}
+
 	// Return user profile without sensitive information
 	c.JSON(http.StatusOK, gin.H{
-		"id":         user.ID,
-		"username":   user.UserName,
-		"role":       user.Role,
-		"created_at": user.CreatedAt,
+		"id":          user.ID,
+		"username":    user.UserName,
+		"role":        user.Role.Role,
+		"permissions": user.Role.Granted(),
+		"created_at":  user.CreatedAt,
+		"storage":     usage,
 	})
 }
 
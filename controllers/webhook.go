@@ -0,0 +1,218 @@
+package controllers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/webhooks"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type webhookInput struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret"`
+	Events []string `json:"events"`
+	Active *bool    `json:"active"`
+}
+
+// CreateWebhook subscribes a URL to task events. The signing secret is only
+// returned in this response.
+func CreateWebhook(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	var input webhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format", "details": err.Error()})
+		return
+	}
+
+	webhook := models.Webhook{UserID: userID, Active: true}
+	if errMsg := applyWebhookInput(c.Request.Context(), &webhook, input, true); errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	if err := config.DB.Create(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"webhook": webhook,
+		"secret":  webhook.Secret,
+	})
+}
+
+// GetWebhooks lists the current user's webhooks
+func GetWebhooks(c *gin.Context) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	var hooks []models.Webhook
+	if err := config.DB.Where("user_id = ?", userID).Order("id").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// UpdateWebhook changes a webhook's URL, secret, event filter or active flag
+func UpdateWebhook(c *gin.Context) {
+	webhook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+
+	var input webhookInput
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid input format", "details": err.Error()})
+		return
+	}
+	if errMsg := applyWebhookInput(c.Request.Context(), &webhook, input, false); errMsg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": errMsg})
+		return
+	}
+
+	if err := config.DB.Save(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook", "details": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, webhook)
+}
+
+// DeleteWebhook removes a webhook and its delivery log
+func DeleteWebhook(c *gin.Context) {
+	webhook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+
+	if err := config.DB.Where("webhook_id = ?", webhook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook deliveries"})
+		return
+	}
+	if err := config.DB.Delete(&webhook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted"})
+}
+
+// GetWebhookDeliveries returns a webhook's delivery log, newest first
+func GetWebhookDeliveries(c *gin.Context) {
+	webhook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+
+	query := config.DB.Where("webhook_id = ?", webhook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id desc").Limit(100).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// RedeliverWebhook sends a past delivery's payload again as a new delivery
+func RedeliverWebhook(c *gin.Context) {
+	webhook, ok := loadOwnWebhook(c)
+	if !ok {
+		return
+	}
+
+	var original models.WebhookDelivery
+	if err := config.DB.Where("webhook_id = ?", webhook.ID).First(&original, c.Param("deliveryId")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+		return
+	}
+
+	delivery := models.WebhookDelivery{
+		WebhookID: webhook.ID,
+		Event:     original.Event,
+		Payload:   original.Payload,
+		Status:    models.DeliveryPending,
+	}
+	if err := config.DB.Create(&delivery).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create delivery"})
+		return
+	}
+
+	delivery.Webhook = webhook
+	webhooks.Attempt(&delivery)
+
+	c.JSON(http.StatusOK, delivery)
+}
+
+// loadOwnWebhook fetches the :id webhook, writing an error response unless it
+// belongs to the current user
+func loadOwnWebhook(c *gin.Context) (models.Webhook, bool) {
+	claims := c.MustGet("claims").(jwt.MapClaims)
+	userID := uint(claims["user_id"].(float64))
+
+	var webhook models.Webhook
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return webhook, false
+	}
+
+	if err := config.DB.First(&webhook, id).Error; err != nil || webhook.UserID != userID {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+		return webhook, false
+	}
+	return webhook, true
+}
+
+// applyWebhookInput validates the input and copies it onto the webhook,
+// returning an error message for the client if anything is invalid
+func applyWebhookInput(ctx context.Context, webhook *models.Webhook, input webhookInput, creating bool) string {
+	if input.URL != "" || creating {
+		parsed, err := url.Parse(input.URL)
+		if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+			return "URL must be an absolute http or https URL"
+		}
+		if err := webhooks.CheckURL(ctx, input.URL); err != nil {
+			return "URL must resolve to a public address: " + err.Error()
+		}
+		webhook.URL = input.URL
+	}
+
+	if input.Events != nil || creating {
+		filter := strings.Join(input.Events, ",")
+		if !webhooks.ValidFilter(filter) {
+			return "Events must list known event types, e.g. task.created or task.*"
+		}
+		webhook.Events = filter
+	}
+
+	if input.Secret != "" {
+		webhook.Secret = input.Secret
+	} else if creating {
+		secret := make([]byte, 32)
+		rand.Read(secret)
+		webhook.Secret = hex.EncodeToString(secret)
+	}
+
+	if input.Active != nil {
+		webhook.Active = *input.Active
+	}
+	return ""
+}
@@ -0,0 +1,92 @@
+package events
+
+import (
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/models"
+	"gorm.io/gorm"
+)
+
+// Event types recorded by the controllers
+const (
+	TaskCreated    = "task.created"
+	TaskUpdated    = "task.updated"
+	TaskDeleted    = "task.deleted"
+	CommentCreated = "comment.created"
+	CommentDeleted = "comment.deleted"
+	FileUploaded   = "file.uploaded"
+	FileDeleted    = "file.deleted"
+	FileRestored   = "file.restored"
+
+	// NotificationCreated is only delivered to webhooks, for users who route
+	// a notification type to the webhook channel
+	NotificationCreated = "notification.created"
+)
+
+// Types lists every event type a webhook can subscribe to
+var Types = []string{
+	TaskCreated, TaskUpdated, TaskDeleted,
+	CommentCreated, CommentDeleted,
+	FileUploaded, FileDeleted, FileRestored,
+	NotificationCreated,
+}
+
+// Event describes a change to a task or one of its comments or files
+type Event struct {
+	ID         uint        `json:"id"`
+	Type       string      `json:"type"`
+	TaskID     uint        `json:"task_id"`
+	OwnerID    uint        `json:"owner_id"` // Owner of the task the event concerns
+	ActorID    uint        `json:"actor_id"` // User who made the change
+	Data       interface{} `json:"data"`
+	OccurredAt time.Time   `json:"occurred_at"`
+}
+
+// Handler receives every published event. Events are delivered at least
+// once, so handlers must tolerate duplicates. Returning an error makes the
+// relay publish the event to that handler again later; handlers that
+// succeeded do not see it again.
+type Handler func(Event) error
+
+type subscriber struct {
+	name    string
+	handler Handler
+}
+
+var (
+	mu          sync.RWMutex
+	subscribers []subscriber
+)
+
+// Subscribe registers a handler for all events. The name identifies the
+// handler in the outbox, so it must be unique and stay the same across
+// restarts.
+func Subscribe(name string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	subscribers = append(subscribers, subscriber{name: name, handler: handler})
+}
+
+// Record writes an event to the outbox. Pass the transaction making the
+// change so the event is stored if and only if the change commits.
+func Record(tx *gorm.DB, event Event) error {
+	payload, err := json.Marshal(event.Data)
+	if err != nil {
+		return err
+	}
+	if event.OccurredAt.IsZero() {
+		event.OccurredAt = time.Now()
+	}
+
+	return tx.Create(&models.OutboxEvent{
+		Type:          event.Type,
+		TaskID:        event.TaskID,
+		OwnerID:       event.OwnerID,
+		ActorID:       event.ActorID,
+		Payload:       string(payload),
+		OccurredAt:    event.OccurredAt,
+		NextAttemptAt: event.OccurredAt,
+	}).Error
+}
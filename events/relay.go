@@ -0,0 +1,153 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	relayBatchSize    = 100
+	relayPollInterval = 500 * time.Millisecond
+	relayMaxBackoff   = 10 * time.Minute
+	// relayMaxAttempts is about two hours of retries, after which the event
+	// is left failed for an operator to inspect
+	relayMaxAttempts = 20
+)
+
+// StartRelay publishes outbox events to subscribers until ctx is cancelled.
+// It can run on every server instance: rows are claimed with
+// FOR UPDATE SKIP LOCKED, and marked published only after every handler
+// succeeded, so each event is delivered at least once. The handlers that
+// succeeded are recorded, so a retry only runs the ones that failed. An event
+// still failing after relayMaxAttempts is marked failed and not retried.
+func StartRelay(ctx context.Context) {
+	for ctx.Err() == nil {
+		published, err := relayBatch(ctx)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("events: relay failed: %v", err)
+		}
+		if published < relayBatchSize {
+			select {
+			case <-ctx.Done():
+			case <-time.After(relayPollInterval):
+			}
+		}
+	}
+}
+
+// relayBatch publishes one batch of due events and returns how many it handled
+func relayBatch(ctx context.Context) (int, error) {
+	var count int
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []models.OutboxEvent
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("published_at IS NULL AND failed_at IS NULL AND next_attempt_at <= ?", time.Now()).
+			Order("id").Limit(relayBatchSize).Find(&rows).Error; err != nil {
+			return err
+		}
+		count = len(rows)
+
+		for _, row := range rows {
+			event := Event{
+				ID:         row.ID,
+				Type:       row.Type,
+				TaskID:     row.TaskID,
+				OwnerID:    row.OwnerID,
+				ActorID:    row.ActorID,
+				Data:       json.RawMessage(row.Payload),
+				OccurredAt: row.OccurredAt,
+			}
+
+			handled := map[string]bool{}
+			if row.Handled != "" {
+				for _, name := range strings.Split(row.Handled, ",") {
+					handled[name] = true
+				}
+			}
+
+			err := publish(event, handled)
+			updates := map[string]interface{}{
+				"attempts": row.Attempts + 1,
+				"handled":  joinNames(handled),
+			}
+			switch {
+			case err != nil && row.Attempts+1 >= relayMaxAttempts:
+				updates["last_error"] = err.Error()
+				updates["failed_at"] = time.Now()
+				log.Printf("events: giving up on %s event %d after %d attempts: %v", row.Type, row.ID, row.Attempts+1, err)
+			case err != nil:
+				updates["last_error"] = err.Error()
+				updates["next_attempt_at"] = time.Now().Add(relayBackoff(row.Attempts + 1))
+				log.Printf("events: publishing %s event %d failed: %v", row.Type, row.ID, err)
+			default:
+				updates["published_at"] = time.Now()
+				updates["last_error"] = ""
+			}
+
+			if err := tx.Model(&row).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return count, err
+}
+
+// publish delivers an event to every handler not yet in handled, adding the
+// ones that succeed and collecting the errors of the others
+func publish(event Event, handled map[string]bool) error {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	var errs []error
+	for _, sub := range subscribers {
+		if handled[sub.name] {
+			continue
+		}
+		if err := safeCall(sub.handler, event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sub.name, err))
+			continue
+		}
+		handled[sub.name] = true
+	}
+	return errors.Join(errs...)
+}
+
+// joinNames lists the handled subscribers in a stable order
+func joinNames(handled map[string]bool) string {
+	names := make([]string, 0, len(handled))
+	for name := range handled {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ",")
+}
+
+func safeCall(handler Handler, event Event) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("handler panicked: %v", r)
+		}
+	}()
+	return handler(event)
+}
+
+// relayBackoff doubles the wait after each failed attempt, starting at one second
+func relayBackoff(attempts int) time.Duration {
+	d := time.Second << (attempts - 1)
+	if d <= 0 || d > relayMaxBackoff {
+		return relayMaxBackoff
+	}
+	return d
+}
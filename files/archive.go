@@ -0,0 +1,69 @@
+package files
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/storage"
+)
+
+// WriteArchive streams a zip archive of the given files to w, reading each
+// one from storage as it is written so nothing is buffered in memory. Files
+// with the same name are numbered to keep entries distinct.
+func WriteArchive(ctx context.Context, w io.Writer, list []models.File) error {
+	archive := zip.NewWriter(w)
+	used := make(map[string]bool, len(list))
+
+	for i := range list {
+		file := &list[i]
+		header := &zip.FileHeader{
+			Name:     archiveName(file.FileName, used),
+			Method:   zip.Store,
+			Modified: file.UploadedAt,
+		}
+		if compressible(file.FileType) {
+			header.Method = zip.Deflate
+		}
+
+		entry, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		reader, _, err := storage.Default.Get(ctx, file.FilePath)
+		if err != nil {
+			return fmt.Errorf("failed to open file %d: %w", file.ID, err)
+		}
+		_, err = io.Copy(entry, reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("failed to archive file %d: %w", file.ID, err)
+		}
+	}
+
+	return archive.Close()
+}
+
+// archiveName returns a unique entry name for a file, appending " (2)",
+// " (3)" and so on before the extension of repeated names
+func archiveName(name string, used map[string]bool) string {
+	name = SanitizeName(name)
+	candidate := name
+	ext := path.Ext(name)
+	for n := 2; used[candidate]; n++ {
+		candidate = fmt.Sprintf("%s (%d)%s", strings.TrimSuffix(name, ext), n, ext)
+	}
+	used[candidate] = true
+	return candidate
+}
+
+// compressible reports whether deflating a content type is worthwhile.
+// Images, media and archives are already compressed.
+func compressible(contentType string) bool {
+	base := baseType(contentType)
+	return IsText(base) || strings.HasSuffix(base, "json") || strings.HasSuffix(base, "xml")
+}
@@ -0,0 +1,81 @@
+package files
+
+import (
+	"context"
+	"errors"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// BlobKey is the storage key of the blob with the given hash. Blobs are
+// spread over two directory levels to keep directories small.
+func BlobKey(hash string) string {
+	return "blobs/" + hash[:2] + "/" + hash[2:4] + "/" + hash
+}
+
+// acquireBlob adds a reference to the blob, creating its row if needed, and
+// stores the contents unless they are already present. The row stays locked
+// until tx ends, so a concurrent collectBlob cannot delete the contents in
+// between.
+func acquireBlob(ctx context.Context, tx *gorm.DB, upload *Upload) error {
+	blob := models.Blob{Hash: upload.Hash, Size: upload.Size, RefCount: 1}
+	err := tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "hash"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"ref_count": gorm.Expr("blobs.ref_count + 1")}),
+	}).Create(&blob).Error
+	if err != nil {
+		return err
+	}
+
+	key := BlobKey(upload.Hash)
+	if _, err := storage.Default.Stat(ctx, key); err == nil {
+		return nil // Deduplicated
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return err
+	}
+
+	contents, err := upload.Open()
+	if err != nil {
+		return err
+	}
+	return storage.Default.Put(ctx, key, contents, upload.Size, upload.ContentType)
+}
+
+// releaseBlob drops a reference to the blob. The contents are deleted by
+// collectBlob once the transaction has committed.
+func releaseBlob(tx *gorm.DB, hash string) error {
+	return tx.Model(&models.Blob{}).
+		Where("hash = ? AND ref_count > 0", hash).
+		Update("ref_count", gorm.Expr("ref_count - 1")).Error
+}
+
+// collectBlob deletes the blob's contents and row if nothing references it
+func collectBlob(ctx context.Context, hash string) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var blob models.Blob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("hash = ? AND ref_count = 0", hash).
+			Take(&blob).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil // Still referenced, or already collected
+		}
+		if err != nil {
+			return err
+		}
+
+		if err := storage.Default.Delete(ctx, BlobKey(hash)); err != nil {
+			return err
+		}
+		if err := storage.Default.Delete(ctx, "quarantine/"+hash); err != nil {
+			return err
+		}
+		if err := deletePreviews(ctx, &models.File{BlobHash: hash}); err != nil {
+			return err
+		}
+		return tx.Delete(&blob).Error
+	})
+}
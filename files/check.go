@@ -0,0 +1,328 @@
+package files
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// orphanGrace protects objects written shortly before a check. acquireBlob
+// stores contents before its transaction commits, so a new upload's object
+// can exist for a while before anything in the database refers to it.
+const orphanGrace = time.Hour
+
+// StorageReport lists the inconsistencies between the file records and the
+// objects in storage found by CheckStorage
+type StorageReport struct {
+	CheckedAt time.Time `json:"checked_at"`
+	Objects   int       `json:"objects"` // Objects listed in storage
+
+	MissingContents    []MissingContents  `json:"missing_contents"`     // Files whose contents are gone
+	MissingBlobs       []string           `json:"missing_blobs"`        // Blobs whose contents are gone
+	RefCountMismatches []RefCountMismatch `json:"ref_count_mismatches"` // Blobs whose reference count is wrong
+	UnreferencedBlobs  []string           `json:"unreferenced_blobs"`   // Blobs no file uses that were never collected
+	OrphanedObjects    []string           `json:"orphaned_objects"`     // Objects nothing refers to
+	OrphanedBytes      int64              `json:"orphaned_bytes"`
+
+	Repaired bool `json:"repaired"`
+}
+
+// MissingContents is a file record whose contents are not in storage
+type MissingContents struct {
+	FileID uint   `json:"file_id"`
+	TaskID uint   `json:"task_id"`
+	Key    string `json:"key"`
+}
+
+// RefCountMismatch is a blob whose recorded reference count differs from the
+// number of files using it. Recorded is zero if the blob has no row.
+type RefCountMismatch struct {
+	Hash     string `json:"hash"`
+	Recorded int    `json:"recorded"`
+	Actual   int    `json:"actual"`
+}
+
+// Consistent reports whether the check found nothing to repair
+func (r *StorageReport) Consistent() bool {
+	return len(r.MissingContents) == 0 && len(r.MissingBlobs) == 0 && len(r.RefCountMismatches) == 0 &&
+		len(r.UnreferencedBlobs) == 0 && len(r.OrphanedObjects) == 0
+}
+
+// Summary describes the findings in one line
+func (r *StorageReport) Summary() string {
+	return fmt.Sprintf("%d files with missing contents, %d missing blobs, %d reference count mismatches, %d unreferenced blobs, %d orphaned objects (%d bytes)",
+		len(r.MissingContents), len(r.MissingBlobs), len(r.RefCountMismatches), len(r.UnreferencedBlobs), len(r.OrphanedObjects), r.OrphanedBytes)
+}
+
+// StorageCheck is the scheduled consistency check. It only reports, failing
+// the job if anything is wrong; repairs are left to an admin.
+func StorageCheck(ctx context.Context) error {
+	report, err := CheckStorage(ctx, false)
+	if err != nil {
+		return err
+	}
+	if !report.Consistent() {
+		return errors.New("storage check found " + report.Summary())
+	}
+	return nil
+}
+
+// CheckStorage compares the file and blob records with the objects in
+// storage. With repair set it also deletes file records whose contents are
+// gone, corrects reference counts, collects unreferenced blobs and deletes
+// orphaned objects.
+func CheckStorage(ctx context.Context, repair bool) (*StorageReport, error) {
+	report := &StorageReport{CheckedAt: time.Now()}
+
+	// List storage before reading the database, so anything stored after the
+	// listing started is newer than the records it is compared with
+	objects := make(map[string]storage.Object)
+	err := storage.Default.List(ctx, "", func(object storage.Object) error {
+		objects[object.Key] = object
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+	report.Objects = len(objects)
+
+	var (
+		fileRows  []models.File
+		blobs     []models.Blob
+		uploadIDs []string
+	)
+	// Read every table from one snapshot so the reference counts line up
+	err = config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Select("id", "task_id", "file_path", "blob_hash", "uploaded_at").Find(&fileRows).Error; err != nil {
+			return err
+		}
+		if err := tx.Find(&blobs).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ResumableUpload{}).Pluck("id", &uploadIDs).Error
+	}, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	fileIDs := make(map[string]bool, len(fileRows))
+	filePaths := make(map[string]bool, len(fileRows))
+	refs := make(map[string]int)
+	for _, file := range fileRows {
+		fileIDs[strconv.FormatUint(uint64(file.ID), 10)] = true
+		filePaths[file.FilePath] = true
+		if file.BlobHash != "" {
+			refs[file.BlobHash]++
+		}
+
+		// Infected contents move to quarantine along with FilePath
+		if _, ok := objects[file.FilePath]; !ok && file.UploadedAt.Before(report.CheckedAt) {
+			report.MissingContents = append(report.MissingContents, MissingContents{FileID: file.ID, TaskID: file.TaskID, Key: file.FilePath})
+		}
+	}
+
+	blobHashes := make(map[string]bool, len(blobs))
+	for _, blob := range blobs {
+		blobHashes[blob.Hash] = true
+		if !blob.CreatedAt.Before(report.CheckedAt) {
+			continue
+		}
+		_, stored := objects[BlobKey(blob.Hash)]
+		_, quarantined := objects["quarantine/"+blob.Hash]
+		if !stored && !quarantined {
+			report.MissingBlobs = append(report.MissingBlobs, blob.Hash)
+		}
+		switch actual := refs[blob.Hash]; {
+		case actual != blob.RefCount:
+			report.RefCountMismatches = append(report.RefCountMismatches, RefCountMismatch{Hash: blob.Hash, Recorded: blob.RefCount, Actual: actual})
+		case actual == 0:
+			report.UnreferencedBlobs = append(report.UnreferencedBlobs, blob.Hash)
+		}
+	}
+	for hash, actual := range refs {
+		if !blobHashes[hash] {
+			report.RefCountMismatches = append(report.RefCountMismatches, RefCountMismatch{Hash: hash, Actual: actual})
+		}
+	}
+
+	uploads := make(map[string]bool, len(uploadIDs))
+	for _, id := range uploadIDs {
+		uploads[id] = true
+	}
+	referenced := func(key string) bool {
+		switch {
+		case strings.HasPrefix(key, "blobs/"):
+			hash := path.Base(key)
+			return blobHashes[hash] && key == BlobKey(hash)
+		case strings.HasPrefix(key, "quarantine/files/"):
+			return fileIDs[strings.TrimPrefix(key, "quarantine/files/")]
+		case strings.HasPrefix(key, "quarantine/"):
+			return blobHashes[strings.TrimPrefix(key, "quarantine/")]
+		case strings.HasPrefix(key, "previews/files/"):
+			return fileIDs[firstSegment(key, "previews/files/")]
+		case strings.HasPrefix(key, "previews/"):
+			return blobHashes[firstSegment(key, "previews/")]
+		case strings.HasPrefix(key, "tus/"):
+			return uploads[firstSegment(key, "tus/")]
+		}
+		// Stored before content addressing
+		return filePaths[key]
+	}
+	for key, object := range objects {
+		if object.ModTime.After(report.CheckedAt.Add(-orphanGrace)) || referenced(key) {
+			continue
+		}
+		report.OrphanedObjects = append(report.OrphanedObjects, key)
+		report.OrphanedBytes += object.Size
+	}
+
+	sort.Strings(report.MissingBlobs)
+	sort.Strings(report.UnreferencedBlobs)
+	sort.Strings(report.OrphanedObjects)
+
+	if report.Consistent() {
+		log.Printf("files: storage check found %d objects consistent", report.Objects)
+		return report, nil
+	}
+	log.Printf("files: storage check found %s", report.Summary())
+	if !repair {
+		return report, nil
+	}
+	report.Repaired = true
+	return report, repairStorage(ctx, report)
+}
+
+// firstSegment returns the path segment of key following prefix
+func firstSegment(key, prefix string) string {
+	segment, _, _ := strings.Cut(strings.TrimPrefix(key, prefix), "/")
+	return segment
+}
+
+// repairStorage fixes what CheckStorage found. Each finding is confirmed
+// again before acting on it, since uploads may have changed things since.
+func repairStorage(ctx context.Context, report *StorageReport) error {
+	var errs []error
+	collect := map[string]bool{}
+
+	for _, missing := range report.MissingContents {
+		hash, err := dropMissingFile(ctx, missing.FileID)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("file %d: %w", missing.FileID, err))
+		} else if hash != "" {
+			collect[hash] = true
+		}
+	}
+	for _, mismatch := range report.RefCountMismatches {
+		if err := recountBlob(ctx, mismatch.Hash); err != nil {
+			errs = append(errs, fmt.Errorf("blob %s: %w", mismatch.Hash, err))
+		}
+		collect[mismatch.Hash] = true
+	}
+	for _, hash := range report.UnreferencedBlobs {
+		collect[hash] = true
+	}
+	for _, hash := range report.MissingBlobs {
+		collect[hash] = true
+	}
+
+	// collectBlob only deletes blobs that are unreferenced by now
+	for hash := range collect {
+		if err := collectBlob(ctx, hash); err != nil {
+			errs = append(errs, fmt.Errorf("blob %s: %w", hash, err))
+		}
+	}
+	for _, key := range report.OrphanedObjects {
+		if err := storage.Default.Delete(ctx, key); err != nil {
+			errs = append(errs, fmt.Errorf("object %s: %w", key, err))
+		}
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+	log.Printf("files: storage check repaired %s", report.Summary())
+	return nil
+}
+
+// dropMissingFile deletes a file record whose contents are gone, making the
+// newest remaining version current if it was the current one. It returns the
+// hash of the blob that lost a reference.
+func dropMissingFile(ctx context.Context, fileID uint) (string, error) {
+	var hash string
+	err := config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var file models.File
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&file, fileID).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := storage.Default.Stat(ctx, file.FilePath); !errors.Is(err, storage.ErrNotFound) {
+			return err // Present again, or storage is failing
+		}
+
+		if err := detach(tx, []models.File{file}); err != nil {
+			return err
+		}
+		hash = file.BlobHash
+		if !file.IsCurrent {
+			return nil
+		}
+
+		var latest models.File
+		err = tx.Where("origin_id = ?", file.OriginID).Order("version DESC").Take(&latest).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return tx.Model(&latest).Update("is_current", true).Error
+	})
+	return hash, err
+}
+
+// recountBlob sets a blob's reference count to the number of files using
+// it, creating its row if files use it without one
+func recountBlob(ctx context.Context, hash string) error {
+	return config.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var blob models.Blob
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hash = ?", hash).Take(&blob).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		exists := err == nil
+
+		var count int64
+		if err := tx.Model(&models.File{}).Where("blob_hash = ?", hash).Count(&count).Error; err != nil {
+			return err
+		}
+		if exists {
+			return tx.Model(&blob).Update("ref_count", count).Error
+		}
+		if count == 0 {
+			return nil
+		}
+
+		var file models.File
+		if err := tx.Where("blob_hash = ?", hash).Take(&file).Error; err != nil {
+			return err
+		}
+		return tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&models.Blob{Hash: hash, Size: file.FileSize, RefCount: int(count)}).Error
+	})
+}
@@ -0,0 +1,184 @@
+package files
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/storage"
+	"github.com/gabriel-vasile/mimetype"
+	"gorm.io/gorm"
+)
+
+// Upload is an uploaded file spooled to a temporary file while its hash is
+// computed. Close removes the temporary file.
+type Upload struct {
+	Name        string
+	ContentType string // Detected from the contents
+	Size        int64
+	Hash        string // Hex-encoded SHA-256 of the contents
+	tmp         *os.File
+}
+
+// Spool copies r to a temporary file, hashing it on the way, and detects its
+// type from the contents. It fails with ErrFileTooLarge or ErrTypeNotAllowed
+// if the file breaks the upload limits.
+func Spool(r io.Reader, name string) (*Upload, error) {
+	tmp, err := os.CreateTemp("", "taskmanager-upload-*")
+	if err != nil {
+		return nil, err
+	}
+	upload := &Upload{Name: SanitizeName(name), tmp: tmp}
+
+	hash := sha256.New()
+	maxSize := MaxFileSize()
+	upload.Size, err = io.Copy(io.MultiWriter(tmp, hash), io.LimitReader(r, maxSize+1))
+	if err == nil && upload.Size > maxSize {
+		err = ErrFileTooLarge
+	}
+	if err != nil {
+		upload.Close()
+		return nil, err
+	}
+	upload.Hash = hex.EncodeToString(hash.Sum(nil))
+
+	// Never trust the client's Content-Type
+	contents, err := upload.Open()
+	if err != nil {
+		upload.Close()
+		return nil, err
+	}
+	detected, err := mimetype.DetectReader(contents)
+	if err != nil {
+		upload.Close()
+		return nil, err
+	}
+	if !TypeAllowed(detected) {
+		upload.Close()
+		return nil, ErrTypeNotAllowed
+	}
+	upload.ContentType = detected.String()
+
+	return upload, nil
+}
+
+// Open rewinds the spooled contents for reading
+func (u *Upload) Open() (io.Reader, error) {
+	if _, err := u.tmp.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	return u.tmp, nil
+}
+
+func (u *Upload) Close() error {
+	u.tmp.Close()
+	return os.Remove(u.tmp.Name())
+}
+
+// SanitizeName reduces a client-supplied filename to its last path element
+// without control characters. The name is only ever used as metadata.
+func SanitizeName(name string) string {
+	name = strings.ReplaceAll(name, "\\", "/")
+	name = strings.Map(func(r rune) rune {
+		if unicode.IsControl(r) {
+			return -1
+		}
+		return r
+	}, filepath.Base("/"+name))
+	name = strings.TrimSpace(name)
+	if name == "" || name == "." || name == "/" || name == ".." {
+		return "file"
+	}
+	if len(name) > 255 {
+		name = strings.ToValidUTF8(name[:255], "")
+	}
+	return name
+}
+
+// Attach stores the upload's contents, deduplicated by hash, creates the file
+// record in tx and queues its virus scan. The caller fills in the task and
+// uploader, and sets OriginID to add the upload as a new version of an
+// existing file. A *QuotaError is returned if the upload would take the
+// uploader over their storage quota.
+func Attach(ctx context.Context, tx *gorm.DB, upload *Upload, file *models.File) error {
+	if err := reserveQuota(tx, file.UserID, upload.Size); err != nil {
+		return err
+	}
+	if err := acquireBlob(ctx, tx, upload); err != nil {
+		return err
+	}
+
+	file.BlobHash = upload.Hash
+	file.FilePath = BlobKey(upload.Hash)
+	file.FileName = upload.Name
+	file.FileType = upload.ContentType
+	file.FileSize = upload.Size
+	file.ScanStatus = models.ScanPending
+	if err := addVersion(tx, file); err != nil {
+		return err
+	}
+	return EnqueueScan(tx, file.ID)
+}
+
+// Detach deletes every version of the file in tx and drops their references
+// to the blobs. Call Purge on the returned versions after the transaction
+// commits to free unreferenced contents.
+func Detach(tx *gorm.DB, file *models.File) ([]models.File, error) {
+	var versions []models.File
+	if err := tx.Where("origin_id = ?", file.OriginID).Find(&versions).Error; err != nil {
+		return nil, err
+	}
+	if len(versions) == 0 {
+		versions = []models.File{*file}
+	}
+	return versions, detach(tx, versions)
+}
+
+// DetachTask deletes every file of a task in tx like Detach
+func DetachTask(tx *gorm.DB, taskID uint) ([]models.File, error) {
+	var taskFiles []models.File
+	if err := tx.Where("task_id = ?", taskID).Find(&taskFiles).Error; err != nil {
+		return nil, err
+	}
+	return taskFiles, detach(tx, taskFiles)
+}
+
+func detach(tx *gorm.DB, list []models.File) error {
+	for _, file := range list {
+		if err := tx.Delete(&file).Error; err != nil {
+			return err
+		}
+		if file.BlobHash == "" {
+			continue
+		}
+		if err := releaseBlob(tx, file.BlobHash); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Purge deletes the contents of detached files unless other files share them
+func Purge(ctx context.Context, versions []models.File) error {
+	var errs []error
+	for _, file := range versions {
+		var err error
+		if file.BlobHash == "" {
+			// Stored before content addressing; the contents belong to this file alone
+			err = errors.Join(storage.Default.Delete(ctx, file.FilePath), deletePreviews(ctx, &file))
+		} else {
+			err = collectBlob(ctx, file.BlobHash)
+		}
+		if err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
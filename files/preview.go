@@ -0,0 +1,231 @@
+package files
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"io"
+	"log"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	// Register decoders for the image formats thumbnails are made from
+	_ "image/gif"
+	_ "image/png"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/queue"
+	"github.com/Chamanthra/TaskManager/storage"
+	"golang.org/x/image/draw"
+	"gorm.io/gorm"
+)
+
+// PreviewJob is the queue job type that generates thumbnails or a text
+// preview for a file
+const PreviewJob = "file.preview"
+
+const (
+	// maxImagePixels guards against decompression bombs
+	maxImagePixels = 50_000_000
+
+	textPreviewBytes = 4096
+	textPreviewLines = 50
+)
+
+// ThumbnailSizes maps each thumbnail size name to its longest edge in pixels
+var ThumbnailSizes = map[string]int{
+	"small":  128,
+	"medium": 512,
+}
+
+type previewPayload struct {
+	FileID uint `json:"file_id"`
+}
+
+// EnqueuePreview queues preview generation for a file
+func EnqueuePreview(tx *gorm.DB, fileID uint) error {
+	return queue.Enqueue(tx, PreviewJob, previewPayload{FileID: fileID})
+}
+
+// previewPrefix is the storage prefix of a file's previews. Previews of
+// content-addressed files are shared like the contents.
+func previewPrefix(file *models.File) string {
+	if file.BlobHash != "" {
+		return "previews/" + file.BlobHash + "/"
+	}
+	return "previews/files/" + strconv.FormatUint(uint64(file.ID), 10) + "/"
+}
+
+// ThumbnailKey is the storage key of a JPEG thumbnail of the given size
+func ThumbnailKey(file *models.File, size string) string {
+	return previewPrefix(file) + size + ".jpg"
+}
+
+// TextPreviewKey is the storage key of a text file's preview
+func TextPreviewKey(file *models.File) string {
+	return previewPrefix(file) + "text.txt"
+}
+
+// IsImage reports whether thumbnails can be made for a content type
+func IsImage(contentType string) bool {
+	switch baseType(contentType) {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// IsText reports whether a text preview can be made for a content type
+func IsText(contentType string) bool {
+	return strings.HasPrefix(baseType(contentType), "text/")
+}
+
+func baseType(contentType string) string {
+	base, _, _ := strings.Cut(contentType, ";")
+	return strings.TrimSpace(base)
+}
+
+func generatePreview(ctx context.Context, p previewPayload) error {
+	var file models.File
+	err := config.DB.WithContext(ctx).First(&file, p.FileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if file.ScanStatus != models.ScanClean || file.Preview != "" {
+		return nil
+	}
+
+	var kind string
+	switch {
+	case IsImage(file.FileType):
+		kind, err = models.PreviewImage, generateThumbnails(ctx, &file)
+	case IsText(file.FileType):
+		kind, err = models.PreviewText, generateTextPreview(ctx, &file)
+	default:
+		return nil
+	}
+	if errors.Is(err, errUnsupportedImage) {
+		kind, err = models.PreviewNone, nil
+	}
+	if err != nil && queue.IsFinalAttempt(ctx) {
+		// Without a final status the preview would be reported as still
+		// being generated forever
+		log.Printf("files: giving up on previews of file %d: %v", file.ID, err)
+		if updateErr := config.DB.Model(&file).Update("preview", models.PreviewNone).Error; updateErr != nil {
+			return errors.Join(err, updateErr)
+		}
+		return err
+	}
+	if err != nil {
+		return err
+	}
+
+	return config.DB.Model(&file).Update("preview", kind).Error
+}
+
+var errUnsupportedImage = errors.New("image cannot be previewed")
+
+func generateThumbnails(ctx context.Context, file *models.File) error {
+	reader, _, err := storage.Default.Get(ctx, file.FilePath)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	// Check the dimensions before decoding the pixels
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil || cfg.Width*cfg.Height > maxImagePixels {
+		return errUnsupportedImage
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return errUnsupportedImage
+	}
+
+	for name, edge := range ThumbnailSizes {
+		var buf bytes.Buffer
+		if err := jpeg.Encode(&buf, thumbnail(src, edge), &jpeg.Options{Quality: 85}); err != nil {
+			return err
+		}
+		if err := storage.Default.Put(ctx, ThumbnailKey(file, name), &buf, int64(buf.Len()), "image/jpeg"); err != nil {
+			return fmt.Errorf("failed to store %s thumbnail: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// thumbnail scales src to fit within edge pixels on a white background,
+// since JPEG has no transparency. Images are never enlarged.
+func thumbnail(src image.Image, edge int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width > edge || height > edge {
+		if width >= height {
+			height = max(1, height*edge/width)
+			width = edge
+		} else {
+			width = max(1, width*edge/height)
+			height = edge
+		}
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, width, height))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, bounds, draw.Over, nil)
+	return dst
+}
+
+func generateTextPreview(ctx context.Context, file *models.File) error {
+	reader, _, err := storage.Default.Get(ctx, file.FilePath)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(reader, textPreviewBytes))
+	reader.Close()
+	if err != nil {
+		return err
+	}
+
+	// Cut at a line limit and never in the middle of a UTF-8 sequence
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	if len(lines) > textPreviewLines {
+		data = bytes.Join(lines[:textPreviewLines], nil)
+	}
+	for i := 0; i < utf8.UTFMax && len(data) > 0 && !utf8.Valid(data); i++ {
+		data = data[:len(data)-1]
+	}
+	data = bytes.ToValidUTF8(data, []byte("\uFFFD"))
+
+	return storage.Default.Put(ctx, TextPreviewKey(file), bytes.NewReader(data), int64(len(data)), "text/plain; charset=utf-8")
+}
+
+// deletePreviews removes any previews stored under a file's prefix
+func deletePreviews(ctx context.Context, file *models.File) error {
+	keys := []string{TextPreviewKey(file)}
+	for name := range ThumbnailSizes {
+		keys = append(keys, ThumbnailKey(file, name))
+	}
+
+	var errs []error
+	for _, key := range keys {
+		if err := storage.Default.Delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,108 @@
+package files
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Chamanthra/TaskManager/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrQuotaExceeded matches a *QuotaError with errors.Is
+var ErrQuotaExceeded = errors.New("storage quota exceeded")
+
+// QuotaError is returned when storing a file would take a user over their
+// storage quota
+type QuotaError struct {
+	Used  int64 // Bytes already used
+	Quota int64
+	Size  int64 // Bytes that were to be added
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("storage quota exceeded: %d of %d bytes used, %d more requested", e.Used, e.Quota, e.Size)
+}
+
+func (e *QuotaError) Is(target error) bool {
+	return target == ErrQuotaExceeded
+}
+
+// Usage is a user's storage use. Every version of every file the user
+// uploaded counts at its full size, even where contents are shared.
+type Usage struct {
+	Used  int64 `json:"used"`
+	Quota int64 `json:"quota"` // Zero means unlimited
+}
+
+// DefaultQuota is the quota of users without their own, set in bytes by
+// USER_STORAGE_QUOTA. Unset means unlimited.
+func DefaultQuota() int64 {
+	return sizeFromEnv("USER_STORAGE_QUOTA", 0)
+}
+
+// QuotaOf returns a user's quota in bytes, zero meaning unlimited
+func QuotaOf(user *models.User) int64 {
+	if user.StorageQuota != nil {
+		return *user.StorageQuota
+	}
+	return DefaultQuota()
+}
+
+// UsageOf returns a user's storage use and quota
+func UsageOf(db *gorm.DB, user *models.User) (Usage, error) {
+	var used int64
+	err := db.Model(&models.File{}).Where("user_id = ?", user.ID).
+		Select("COALESCE(SUM(file_size), 0)").Scan(&used).Error
+	return Usage{Used: used, Quota: QuotaOf(user)}, err
+}
+
+// UsageByUser returns the bytes used by every user who has uploaded files
+func UsageByUser(db *gorm.DB) (map[uint]int64, error) {
+	var rows []struct {
+		UserID uint
+		Used   int64
+	}
+	err := db.Model(&models.File{}).Select("user_id, SUM(file_size) AS used").Group("user_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		usage[row.UserID] = row.Used
+	}
+	return usage, nil
+}
+
+// CheckQuota returns a *QuotaError if the user cannot store size more bytes.
+// It is an early check for uploads of a known size; reserveQuota makes the
+// final decision when the file is recorded.
+func CheckQuota(db *gorm.DB, userID uint, size int64) error {
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		return err
+	}
+	return checkQuota(db, &user, size)
+}
+
+// reserveQuota checks the quota in tx while holding a lock on the user, so
+// concurrent uploads cannot together exceed it
+func reserveQuota(tx *gorm.DB, userID uint, size int64) error {
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&user, userID).Error; err != nil {
+		return err
+	}
+	return checkQuota(tx, &user, size)
+}
+
+func checkQuota(db *gorm.DB, user *models.User, size int64) error {
+	usage, err := UsageOf(db, user)
+	if err != nil {
+		return err
+	}
+	if usage.Quota > 0 && usage.Used+size > usage.Quota {
+		return &QuotaError{Used: usage.Used, Quota: usage.Quota, Size: size}
+	}
+	return nil
+}
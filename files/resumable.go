@@ -0,0 +1,230 @@
+package files
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/storage"
+	"gorm.io/gorm"
+)
+
+// UploadExpiry is how long an unfinished resumable upload is kept after its
+// last chunk
+const UploadExpiry = 24 * time.Hour
+
+var (
+	ErrOffsetMismatch = errors.New("upload offset does not match")
+	ErrUploadTooLarge = errors.New("chunk exceeds upload length")
+)
+
+// NewUploadID returns a random identifier for a resumable upload
+func NewUploadID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newPartKey returns a storage key for one attempt at writing a part. The
+// random suffix keeps clients retrying at the same offset from overwriting
+// each other's data.
+func newPartKey(uploadID string, offset int64) (string, error) {
+	suffix, err := NewUploadID()
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("tus/%s/%020d-%s", uploadID, offset, suffix), nil
+}
+
+func partKey(uploadID string, part models.ResumableUploadPart) string {
+	if part.Key != "" {
+		return part.Key
+	}
+	return fmt.Sprintf("tus/%s/%020d", uploadID, part.Offset)
+}
+
+// WritePart stores the bytes read from r as the next part of the upload,
+// starting at offset, and advances the upload's offset. If r fails part way
+// the bytes received so far are still kept so the client can resume, and the
+// read error is returned.
+func WritePart(ctx context.Context, upload *models.ResumableUpload, offset int64, r io.Reader) error {
+	if offset != upload.Offset {
+		return ErrOffsetMismatch
+	}
+
+	tmp, err := os.CreateTemp("", "taskmanager-chunk-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	remaining := upload.Length - upload.Offset
+	size, readErr := io.Copy(tmp, io.LimitReader(r, remaining+1))
+	if size > remaining {
+		return ErrUploadTooLarge
+	}
+	if size == 0 {
+		return readErr
+	}
+
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	key, err := newPartKey(upload.ID, offset)
+	if err != nil {
+		return err
+	}
+	if err := storage.Default.Put(ctx, key, tmp, size, ""); err != nil {
+		return err
+	}
+
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		// Only advance from the offset this chunk was written at, so two
+		// clients racing on the same upload cannot both succeed
+		result := tx.Model(upload).Where("upload_offset = ?", offset).Updates(map[string]interface{}{
+			"upload_offset": offset + size,
+			"expires_at":    time.Now().Add(UploadExpiry),
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOffsetMismatch
+		}
+		return tx.Create(&models.ResumableUploadPart{UploadID: upload.ID, Offset: offset, Size: size, Key: key}).Error
+	})
+	if err != nil {
+		// The key is unique to this call, so this cannot remove a part
+		// another request committed
+		storage.Default.Delete(ctx, key)
+		return err
+	}
+
+	upload.Offset = offset + size
+	return readErr
+}
+
+// OpenParts returns a reader over the upload's parts in order. Parts are
+// fetched from storage one at a time as the reader reaches them.
+func OpenParts(ctx context.Context, upload *models.ResumableUpload) (io.ReadCloser, error) {
+	var parts []models.ResumableUploadPart
+	if err := config.DB.Where("upload_id = ?", upload.ID).Order("part_offset").Find(&parts).Error; err != nil {
+		return nil, err
+	}
+
+	var next int64
+	for _, part := range parts {
+		if part.Offset != next {
+			return nil, fmt.Errorf("upload %s is missing data at offset %d", upload.ID, next)
+		}
+		next += part.Size
+	}
+	if next != upload.Length {
+		return nil, fmt.Errorf("upload %s has %d of %d bytes", upload.ID, next, upload.Length)
+	}
+
+	return &partReader{ctx: ctx, uploadID: upload.ID, parts: parts}, nil
+}
+
+type partReader struct {
+	ctx      context.Context
+	uploadID string
+	parts    []models.ResumableUploadPart
+	current  io.ReadCloser
+}
+
+func (r *partReader) Read(p []byte) (int, error) {
+	for {
+		if r.current == nil {
+			if len(r.parts) == 0 {
+				return 0, io.EOF
+			}
+			reader, _, err := storage.Default.Get(r.ctx, partKey(r.uploadID, r.parts[0]))
+			if err != nil {
+				return 0, err
+			}
+			r.current = reader
+			r.parts = r.parts[1:]
+		}
+
+		n, err := r.current.Read(p)
+		if err == io.EOF {
+			r.current.Close()
+			r.current = nil
+			if n == 0 {
+				continue
+			}
+			err = nil
+		}
+		return n, err
+	}
+}
+
+func (r *partReader) Close() error {
+	if r.current != nil {
+		return r.current.Close()
+	}
+	return nil
+}
+
+// DiscardParts deletes the stored parts of an upload
+func DiscardParts(ctx context.Context, upload *models.ResumableUpload) error {
+	var parts []models.ResumableUploadPart
+	if err := config.DB.Where("upload_id = ?", upload.ID).Find(&parts).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, part := range parts {
+		if err := storage.Default.Delete(ctx, partKey(upload.ID, part)); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if err := config.DB.Delete(&part).Error; err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// DiscardUpload deletes an upload and its stored parts
+func DiscardUpload(ctx context.Context, upload *models.ResumableUpload) error {
+	if err := DiscardParts(ctx, upload); err != nil {
+		return err
+	}
+	return config.DB.Delete(upload).Error
+}
+
+// ExpireUploads deletes resumable uploads past their expiry, whether or not
+// they were completed
+func ExpireUploads(ctx context.Context) error {
+	var uploads []models.ResumableUpload
+	if err := config.DB.WithContext(ctx).Where("expires_at < ?", time.Now()).Find(&uploads).Error; err != nil {
+		return err
+	}
+
+	var errs []error
+	for i := range uploads {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		if err := DiscardUpload(ctx, &uploads[i]); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if len(uploads) > 0 {
+		log.Printf("uploads: expired %d resumable uploads", len(uploads))
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,228 @@
+package files
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/notifications"
+	"github.com/Chamanthra/TaskManager/queue"
+	"github.com/Chamanthra/TaskManager/scanner"
+	"github.com/Chamanthra/TaskManager/storage"
+	"gorm.io/gorm"
+)
+
+// ScanJob is the queue job type that virus scans a file
+const ScanJob = "file.scan"
+
+// ErrQuarantined is returned when restoring a version found to be infected
+var ErrQuarantined = errors.New("file is quarantined")
+
+type scanPayload struct {
+	FileID uint `json:"file_id"`
+}
+
+// RegisterJobs installs the file job handlers on the queue
+func RegisterJobs() {
+	queue.Handle(ScanJob, scanFile)
+	queue.Handle(PreviewJob, generatePreview)
+}
+
+// EnqueueScan queues a virus scan of the file. Pass the transaction creating
+// the file so the scan cannot run before the record exists.
+func EnqueueScan(tx *gorm.DB, fileID uint) error {
+	return queue.Enqueue(tx, ScanJob, scanPayload{FileID: fileID})
+}
+
+// QuarantineKey is the storage key infected contents are moved to
+func QuarantineKey(file *models.File) string {
+	if file.BlobHash != "" {
+		return "quarantine/" + file.BlobHash
+	}
+	return "quarantine/files/" + strconv.FormatUint(uint64(file.ID), 10)
+}
+
+func scanFile(ctx context.Context, p scanPayload) error {
+	var file models.File
+	err := config.DB.WithContext(ctx).First(&file, p.FileID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil // Deleted before it was scanned
+	}
+	if err != nil {
+		return err
+	}
+	if file.ScanStatus != models.ScanPending && file.ScanStatus != models.ScanError {
+		return nil // Already scanned
+	}
+
+	reader, _, err := storage.Default.Get(ctx, file.FilePath)
+	if err != nil {
+		return recordScanError(&file, err)
+	}
+	result, err := scanner.Default.Scan(ctx, reader)
+	reader.Close()
+	if errors.Is(err, scanner.ErrTooLarge) {
+		return recordTooLarge(&file, err)
+	}
+	if err != nil {
+		return recordScanError(&file, err)
+	}
+
+	if result.Infected {
+		return quarantine(ctx, &file, result.Signature)
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&file).Updates(map[string]interface{}{
+			"scan_status": models.ScanClean,
+			"scan_result": "",
+			"scanned_at":  time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		message := fmt.Sprintf("File %q passed the virus scan and is ready to download", file.FileName)
+		if err := notifications.Enqueue(tx, file.UserID, file.TaskID, models.NotificationFileScan, message); err != nil {
+			return err
+		}
+
+		// Previews are only made from contents known to be clean
+		if IsImage(file.FileType) || IsText(file.FileType) {
+			return EnqueuePreview(tx, file.ID)
+		}
+		return nil
+	})
+}
+
+// recordScanError marks the file as failed to scan, so it stays blocked, and
+// returns err so the queue retries the scan
+func recordScanError(file *models.File, err error) error {
+	updateErr := config.DB.Model(file).Updates(map[string]interface{}{
+		"scan_status": models.ScanError,
+		"scan_result": truncate(err.Error()),
+		"scanned_at":  time.Now(),
+	}).Error
+	if updateErr != nil {
+		log.Printf("files: failed to record scan error for file %d: %v", file.ID, updateErr)
+	}
+	return err
+}
+
+// recordTooLarge marks a file the scanner refused for its size. It stays
+// blocked without being retried, and the uploader is told why.
+func recordTooLarge(file *models.File, err error) error {
+	log.Printf("files: file %d is too large to scan: %v", file.ID, err)
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(file).Updates(map[string]interface{}{
+			"scan_status": models.ScanTooLarge,
+			"scan_result": truncate(err.Error()),
+			"scanned_at":  time.Now(),
+		}).Error
+		if err != nil {
+			return err
+		}
+		message := fmt.Sprintf("File %q is too large to be scanned for viruses and cannot be downloaded", file.FileName)
+		return notifications.Enqueue(tx, file.UserID, file.TaskID, models.NotificationFileScan, message)
+	})
+}
+
+// quarantine moves infected contents out of the blob store and marks every
+// file sharing them as infected
+func quarantine(ctx context.Context, file *models.File, signature string) error {
+	key := QuarantineKey(file)
+	if !strings.HasPrefix(file.FilePath, "quarantine/") {
+		if err := move(ctx, file.FilePath, key); err != nil {
+			return recordScanError(file, err)
+		}
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.File{})
+		if file.BlobHash != "" {
+			query = query.Where("blob_hash = ?", file.BlobHash)
+		} else {
+			query = query.Where("id = ?", file.ID)
+		}
+
+		var affected []models.File
+		if err := query.Find(&affected).Error; err != nil {
+			return err
+		}
+		ids := make([]uint, len(affected))
+		for i, f := range affected {
+			ids[i] = f.ID
+		}
+
+		err := tx.Model(&models.File{}).Where("id IN ?", ids).Updates(map[string]interface{}{
+			"scan_status": models.ScanInfected,
+			"scan_result": signature,
+			"scanned_at":  time.Now(),
+			"file_path":   key,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		for _, f := range affected {
+			if f.ScanStatus == models.ScanInfected {
+				continue // Already reported
+			}
+			message := fmt.Sprintf("File %q contains %s and has been quarantined", f.FileName, signature)
+			if err := notifications.Enqueue(tx, f.UserID, f.TaskID, models.NotificationFileScan, message); err != nil {
+				return err
+			}
+		}
+		log.Printf("files: quarantined %s (%s), affecting %d files", file.FilePath, signature, len(affected))
+		return nil
+	})
+}
+
+// move copies an object to a new key and deletes the original
+func move(ctx context.Context, from, to string) error {
+	reader, object, err := storage.Default.Get(ctx, from)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	if err := storage.Default.Put(ctx, to, reader, object.Size, object.ContentType); err != nil {
+		return err
+	}
+	return storage.Default.Delete(ctx, from)
+}
+
+// RescanPending queues scans for files whose scan was lost, such as files
+// uploaded before scanning was introduced, and retries failed scans hourly
+func RescanPending(ctx context.Context) error {
+	var ids []uint
+	err := config.DB.WithContext(ctx).Model(&models.File{}).
+		Where("(scan_status = ? AND scanned_at IS NULL AND uploaded_at < ?) OR (scan_status = ? AND scanned_at < ?)",
+			models.ScanPending, time.Now().Add(-15*time.Minute), models.ScanError, time.Now().Add(-time.Hour)).
+		Pluck("id", &ids).Error
+	if err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := EnqueueScan(config.DB, id); err != nil {
+			return err
+		}
+	}
+	if len(ids) > 0 {
+		log.Printf("files: queued %d files for rescanning", len(ids))
+	}
+	return nil
+}
+
+func truncate(s string) string {
+	if len(s) > 1000 {
+		return s[:1000]
+	}
+	return s
+}
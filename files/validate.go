@@ -0,0 +1,71 @@
+package files
+
+import (
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+const (
+	defaultMaxFileSize = 100 << 20 // 100 MiB
+
+	// multipartOverhead allows for the multipart headers and boundaries
+	// wrapped around a file in an upload request
+	multipartOverhead = 1 << 20
+)
+
+var (
+	ErrFileTooLarge   = errors.New("file exceeds the maximum upload size")
+	ErrTypeNotAllowed = errors.New("file type is not allowed")
+)
+
+// MaxFileSize is the largest file that can be uploaded, set in bytes by
+// UPLOAD_MAX_FILE_SIZE
+func MaxFileSize() int64 {
+	return sizeFromEnv("UPLOAD_MAX_FILE_SIZE", defaultMaxFileSize)
+}
+
+// MaxRequestSize is the largest upload request body accepted, set in bytes by
+// UPLOAD_MAX_REQUEST_SIZE. It defaults to room for one file of MaxFileSize.
+func MaxRequestSize() int64 {
+	return sizeFromEnv("UPLOAD_MAX_REQUEST_SIZE", MaxFileSize()+multipartOverhead)
+}
+
+func sizeFromEnv(name string, fallback int64) int64 {
+	if value := os.Getenv(name); value != "" {
+		if size, err := strconv.ParseInt(value, 10, 64); err == nil && size > 0 {
+			return size
+		}
+	}
+	return fallback
+}
+
+// TypeAllowed reports whether a detected MIME type may be uploaded.
+// UPLOAD_ALLOWED_TYPES holds a comma-separated allowlist of exact types and
+// "type/*" wildcards, e.g. "image/*,application/pdf". Every type is allowed
+// when it is empty.
+func TypeAllowed(mime *mimetype.MIME) bool {
+	allowed := os.Getenv("UPLOAD_ALLOWED_TYPES")
+	if strings.TrimSpace(allowed) == "" {
+		return true
+	}
+
+	base, _, _ := strings.Cut(mime.String(), ";")
+	for _, pattern := range strings.Split(allowed, ",") {
+		pattern = strings.ToLower(strings.TrimSpace(pattern))
+		switch {
+		case pattern == "*" || pattern == "*/*":
+			return true
+		case strings.HasSuffix(pattern, "/*"):
+			if strings.HasPrefix(base, strings.TrimSuffix(pattern, "*")) {
+				return true
+			}
+		case pattern != "" && mime.Is(pattern):
+			return true
+		}
+	}
+	return false
+}
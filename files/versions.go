@@ -0,0 +1,124 @@
+package files
+
+import (
+	"errors"
+
+	"github.com/Chamanthra/TaskManager/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotRestorable is returned for versions stored before content addressing,
+// whose contents cannot be shared with a new version
+var ErrNotRestorable = errors.New("version cannot be restored")
+
+// addVersion inserts file as the current version of the chain named by its
+// OriginID, or as the first version of a new chain if OriginID is zero
+func addVersion(tx *gorm.DB, file *models.File) error {
+	file.IsCurrent = true
+	if file.OriginID == 0 {
+		file.Version = 1
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+		file.OriginID = file.ID
+		return tx.Model(file).Update("origin_id", file.ID).Error
+	}
+
+	// Lock the chain so concurrent uploads get distinct version numbers. The
+	// latest version is read by a separate statement after the locks are
+	// granted: a statement that waited for a lock still sees the snapshot from
+	// before the wait, which lacks the version the other upload just added.
+	var locked []uint
+	err := tx.Model(&models.File{}).Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("origin_id = ?", file.OriginID).
+		Pluck("id", &locked).Error
+	if err != nil {
+		return err
+	}
+	if len(locked) == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	var latest int
+	err = tx.Model(&models.File{}).Where("origin_id = ?", file.OriginID).
+		Select("COALESCE(MAX(version), 0)").Scan(&latest).Error
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(&models.File{}).Where("origin_id = ? AND is_current", file.OriginID).Update("is_current", false).Error; err != nil {
+		return err
+	}
+
+	file.Version = latest + 1
+	return tx.Create(file).Error
+}
+
+// UploaderColumns narrows a Preload of a file's User to the fields that may
+// be shown to other users
+func UploaderColumns(db *gorm.DB) *gorm.DB {
+	return db.Select("id", "user_name")
+}
+
+// Versions returns every version of a file, newest first, with the user
+// name of each version's uploader
+func Versions(db *gorm.DB, originID uint) ([]models.File, error) {
+	var versions []models.File
+	err := db.Preload("User", UploaderColumns).Where("origin_id = ?", originID).Order("version DESC").Find(&versions).Error
+	return versions, err
+}
+
+// Version returns one version of a file, or the current version if version
+// is zero
+func Version(db *gorm.DB, originID uint, version int) (*models.File, error) {
+	query := db.Where("origin_id = ?", originID)
+	if version == 0 {
+		query = query.Where("is_current")
+	} else {
+		query = query.Where("version = ?", version)
+	}
+
+	var file models.File
+	if err := query.Take(&file).Error; err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
+
+// Restore makes an older version current again by adding a new version with
+// the same contents, so the history is preserved. The copy counts towards the
+// storage quota of the user restoring it.
+func Restore(tx *gorm.DB, old *models.File, userID uint) (*models.File, error) {
+	if old.BlobHash == "" {
+		return nil, ErrNotRestorable
+	}
+	if old.ScanStatus == models.ScanInfected {
+		return nil, ErrQuarantined
+	}
+	if err := reserveQuota(tx, userID, old.FileSize); err != nil {
+		return nil, err
+	}
+
+	err := tx.Model(&models.Blob{}).Where("hash = ?", old.BlobHash).
+		Update("ref_count", gorm.Expr("ref_count + 1")).Error
+	if err != nil {
+		return nil, err
+	}
+
+	restored := models.File{
+		FilePath: old.FilePath,
+		BlobHash: old.BlobHash,
+		FileName: old.FileName,
+		FileType: old.FileType,
+		FileSize: old.FileSize,
+		TaskID:   old.TaskID,
+		UserID:   userID,
+		OriginID: old.OriginID,
+
+		ScanStatus: models.ScanPending,
+	}
+	if err := addVersion(tx, &restored); err != nil {
+		return nil, err
+	}
+	return &restored, EnqueueScan(tx, restored.ID)
+}
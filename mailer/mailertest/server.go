@@ -0,0 +1,132 @@
+// Package mailertest provides a minimal SMTP server for testing mail
+// delivery without a sink such as MailHog.
+package mailertest
+
+import (
+	"net"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// Mail is a message accepted by the server
+type Mail struct {
+	From string
+	To   []string
+	Data string // Headers and body, with dot-stuffing removed
+}
+
+// Server accepts SMTP connections on a local port and records the messages
+// it receives
+type Server struct {
+	Host string
+	Port string
+
+	listener  net.Listener
+	mu        sync.Mutex
+	rcptReply string
+	messages  []Mail
+}
+
+// NewServer starts a server and points the mailer at it through the SMTP_*
+// environment variables. It is stopped when the test ends.
+func NewServer(t testing.TB) *Server {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("mailertest: %v", err)
+	}
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	s := &Server{Host: host, Port: port, rcptReply: "250 OK", listener: listener}
+	t.Cleanup(func() { listener.Close() })
+
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("SMTP_USERNAME", "")
+	t.Setenv("SMTP_FROM", "taskmanager@example.com")
+
+	go s.serve()
+	return s
+}
+
+// RejectRecipients makes the server reply to RCPT TO with reply, such as
+// "550 No such user", instead of "250 OK"
+func (s *Server) RejectRecipients(reply string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rcptReply = reply
+}
+
+// Messages returns the messages received so far
+func (s *Server) Messages() []Mail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Mail(nil), s.messages...)
+}
+
+func (s *Server) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	text := textproto.NewConn(conn)
+	reply := func(line string) { text.PrintfLine("%s", line) }
+
+	reply("220 mailertest ready")
+	var mail Mail
+	for {
+		line, err := text.ReadLine()
+		if err != nil {
+			return
+		}
+		verb, arg, _ := strings.Cut(line, " ")
+		switch strings.ToUpper(verb) {
+		case "EHLO", "HELO":
+			reply("250 mailertest")
+		case "MAIL":
+			mail = Mail{From: address(arg)}
+			reply("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			rcptReply := s.rcptReply
+			s.mu.Unlock()
+			if strings.HasPrefix(rcptReply, "2") {
+				mail.To = append(mail.To, address(arg))
+			}
+			reply(rcptReply)
+		case "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			data, err := text.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			mail.Data = string(data)
+			s.mu.Lock()
+			s.messages = append(s.messages, mail)
+			s.mu.Unlock()
+			reply("250 OK")
+		case "RSET", "NOOP":
+			reply("250 OK")
+		case "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+// address extracts the address from "FROM:<a@b>" or "TO:<a@b>"
+func address(arg string) string {
+	_, addr, _ := strings.Cut(arg, ":")
+	addr, _, _ = strings.Cut(addr, " ")
+	return strings.Trim(addr, "<>")
+}
@@ -0,0 +1,111 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"time"
+)
+
+// Message is a single email with plain-text and HTML alternatives
+type Message struct {
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// ErrNotConfigured is returned when SMTP_HOST is not set
+var ErrNotConfigured = errors.New("SMTP_HOST is not set in environment")
+
+// Send delivers a message through the SMTP server configured by the
+// SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD and SMTP_FROM
+// environment variables. Authentication is skipped when no username is set,
+// which suits local SMTP sinks such as MailHog.
+func Send(msg Message) error {
+	host := os.Getenv("SMTP_HOST")
+	if host == "" {
+		return ErrNotConfigured
+	}
+	port := os.Getenv("SMTP_PORT")
+	if port == "" {
+		port = "25"
+	}
+	from := os.Getenv("SMTP_FROM")
+	if from == "" {
+		from = "taskmanager@localhost"
+	}
+
+	var auth smtp.Auth
+	if username := os.Getenv("SMTP_USERNAME"); username != "" {
+		auth = smtp.PlainAuth("", username, os.Getenv("SMTP_PASSWORD"), host)
+	}
+
+	body, err := build(from, msg)
+	if err != nil {
+		return err
+	}
+	return smtp.SendMail(host+":"+port, auth, from, []string{msg.To}, body)
+}
+
+// IsPermanent reports whether an SMTP error is a permanent (5xx) failure,
+// such as a rejected recipient, that must not be retried
+func IsPermanent(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code >= 500
+	}
+	return false
+}
+
+// build renders the message as multipart/alternative MIME
+func build(from string, msg Message) ([]byte, error) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+
+	for _, part := range []struct {
+		contentType string
+		content     string
+	}{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := writer.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		if _, err := w.Write([]byte(part.content)); err != nil {
+			return nil, err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, "From: %s\r\n", from)
+	fmt.Fprintf(&out, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&out, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&out, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&out, "Message-ID: <%s@taskmanager>\r\n", messageID())
+	fmt.Fprintf(&out, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&out, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", writer.Boundary())
+	out.Write(body.Bytes())
+	return out.Bytes(), nil
+}
+
+func messageID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
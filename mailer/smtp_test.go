@@ -0,0 +1,96 @@
+package mailer_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/mailer"
+	"github.com/Chamanthra/TaskManager/mailer/mailertest"
+)
+
+func TestSendNotification(t *testing.T) {
+	server := mailertest.NewServer(t)
+
+	msg, err := mailer.RenderNotification("alice@example.com", "alice", mailer.Item{
+		Message:   "New comment added to your task",
+		TaskTitle: "Write report",
+		CreatedAt: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mailer.Send(msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want 1", len(messages))
+	}
+	got := messages[0]
+	if got.From != "taskmanager@example.com" || len(got.To) != 1 || got.To[0] != "alice@example.com" {
+		t.Errorf("envelope = %s -> %v", got.From, got.To)
+	}
+	for _, want := range []string{
+		"Subject: Task Manager: New comment added to your task",
+		"Content-Type: multipart/alternative",
+		"text/plain; charset=utf-8",
+		"text/html; charset=utf-8",
+		"(task: Write report)",
+	} {
+		if !strings.Contains(got.Data, want) {
+			t.Errorf("message does not contain %q:\n%s", want, got.Data)
+		}
+	}
+}
+
+func TestSendDigest(t *testing.T) {
+	server := mailertest.NewServer(t)
+
+	items := []mailer.Item{
+		{Message: "New comment added to your task", TaskTitle: "Write report", CreatedAt: time.Now()},
+		{Message: "New file uploaded to your task", TaskTitle: "Plan launch", CreatedAt: time.Now()},
+	}
+	msg, err := mailer.RenderDigest("bob@example.com", "bob", items)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mailer.Send(msg); err != nil {
+		t.Fatalf("Send: %v", err)
+	}
+
+	messages := server.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("server received %d messages, want one digest", len(messages))
+	}
+	data := messages[0].Data
+	if !strings.Contains(data, "Subject: Task Manager: you have unread notifications") {
+		t.Errorf("digest subject missing:\n%s", data)
+	}
+	for _, item := range items {
+		if !strings.Contains(data, item.TaskTitle) {
+			t.Errorf("digest does not mention %q", item.TaskTitle)
+		}
+	}
+}
+
+func TestPermanentErrors(t *testing.T) {
+	msg := mailer.Message{To: "nobody@example.com", Subject: "Hi", Text: "Hi", HTML: "<p>Hi</p>"}
+
+	server := mailertest.NewServer(t)
+	server.RejectRecipients("550 No such user")
+	err := mailer.Send(msg)
+	if err == nil || !mailer.IsPermanent(err) {
+		t.Errorf("550 reply: err = %v, want a permanent error", err)
+	}
+
+	server.RejectRecipients("451 Try again later")
+	err = mailer.Send(msg)
+	if err == nil || mailer.IsPermanent(err) {
+		t.Errorf("451 reply: err = %v, want a temporary error", err)
+	}
+	if n := len(server.Messages()); n != 0 {
+		t.Errorf("server accepted %d messages for a rejected recipient", n)
+	}
+}
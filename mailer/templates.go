@@ -0,0 +1,68 @@
+package mailer
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"text/template"
+	"time"
+)
+
+// Item is one notification rendered into an email
+type Item struct {
+	Message   string
+	TaskTitle string
+	CreatedAt time.Time
+}
+
+type templateData struct {
+	UserName string
+	Items    []Item
+}
+
+var textTemplate = template.Must(template.New("text").Parse(`Hi {{.UserName}},
+{{range .Items}}
+- {{.Message}}{{if .TaskTitle}} (task: {{.TaskTitle}}){{end}}
+  {{.CreatedAt.Format "2006-01-02 15:04 MST"}}
+{{end}}
+You are receiving this because email notifications are enabled in your
+Task Manager notification preferences.
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif;">
+<p>Hi {{.UserName}},</p>
+<ul>
+{{range .Items}}<li>{{.Message}}{{if .TaskTitle}} (task: <strong>{{.TaskTitle}}</strong>){{end}}<br>
+<small>{{.CreatedAt.Format "2006-01-02 15:04 MST"}}</small></li>
+{{end}}</ul>
+<p><small>You are receiving this because email notifications are enabled in your
+Task Manager notification preferences.</small></p>
+</body>
+</html>
+`))
+
+// RenderNotification builds the email for a single notification
+func RenderNotification(to, userName string, item Item) (Message, error) {
+	return render(to, "Task Manager: "+item.Message, userName, []Item{item})
+}
+
+// RenderDigest builds one email batching several notifications
+func RenderDigest(to, userName string, items []Item) (Message, error) {
+	subject := "Task Manager: you have unread notifications"
+	return render(to, subject, userName, items)
+}
+
+func render(to, subject, userName string, items []Item) (Message, error) {
+	data := templateData{UserName: userName, Items: items}
+
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, data); err != nil {
+		return Message{}, err
+	}
+	if err := htmlTemplate.Execute(&html, data); err != nil {
+		return Message{}, err
+	}
+
+	return Message{To: to, Subject: subject, Text: text.String(), HTML: html.String()}, nil
+}
@@ -1,10 +1,28 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
 	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/events"
+	"github.com/Chamanthra/TaskManager/files"
 	"github.com/Chamanthra/TaskManager/migrations"
 	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/notifications"
+	"github.com/Chamanthra/TaskManager/queue"
+	"github.com/Chamanthra/TaskManager/realtime"
 	"github.com/Chamanthra/TaskManager/routes"
+	"github.com/Chamanthra/TaskManager/scanner"
+	"github.com/Chamanthra/TaskManager/storage"
+	"github.com/Chamanthra/TaskManager/webhooks"
 	"github.com/Chamanthra/TaskManager/workers"
 )
 
@@ -16,19 +34,119 @@ func main() {
 		&models.User{},
 		&models.Task{},
 		&models.Role{},
+		&models.Permission{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
 		&models.Notification{},
 		&models.Comment{},
 		&models.File{},
+		&models.Blob{},
+		&models.ResumableUpload{},
+		&models.ResumableUploadPart{},
+		&models.NotificationPreference{},
+		&models.NotificationSettings{},
+		&models.TaskMute{},
+		&models.EmailDelivery{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.TaskReminder{},
+		&models.ScheduledJob{},
+		&models.QueuedJob{},
+		&models.OutboxEvent{},
+		&models.RealtimePayload{},
 	)
 
 	// Seed initial data
 	if err := migrations.InitRoles(config.DB); err != nil {
 		panic("Failed to seed roles: " + err.Error())
 	}
+	if err := migrations.InitAdmin(config.DB); err != nil {
+		panic("Failed to create admin user: " + err.Error())
+	}
+	if err := migrations.StorageKeys(config.DB); err != nil {
+		panic("Failed to migrate file paths: " + err.Error())
+	}
+	if err := migrations.FileVersions(config.DB); err != nil {
+		panic("Failed to migrate file versions: " + err.Error())
+	}
+	if err := migrations.WebhookResponses(config.DB); err != nil {
+		panic("Failed to drop webhook responses: " + err.Error())
+	}
 
-	// Start notification worker
-	go workers.StartNotificationWorker()
+	// Select the broker used for real-time notifications
+	if err := realtime.Setup(); err != nil {
+		panic("Failed to start notification broker: " + err.Error())
+	}
+	// Select where uploaded files are stored
+	if err := storage.Setup(); err != nil {
+		panic("Failed to set up file storage: " + err.Error())
+	}
+
+	// Subscribers to the domain events published by the outbox relay
+	events.Subscribe("realtime", realtime.HandleEvent)
+	events.Subscribe("webhooks", webhooks.HandleEvent)
+	events.Subscribe("notifications", notifications.HandleEvent)
+	notifications.RegisterDeliverer(models.ChannelEmail, notifications.DeliverEmail)
+	notifications.RegisterDeliverer(models.ChannelWebhook, webhooks.DeliverNotification)
+	notifications.RegisterJobs()
+
+	// Scan uploaded files for malware
+	if err := scanner.Setup(); err != nil {
+		panic("Failed to set up virus scanner: " + err.Error())
+	}
+	files.RegisterJobs()
 
-	r := routes.SetupRouter()
-	r.Run(":8080")
+	// Stop background jobs and the HTTP server on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	// Start background jobs
+	if err := workers.RegisterJobs(workers.DefaultScheduler); err != nil {
+		panic("Failed to register background jobs: " + err.Error())
+	}
+	var background sync.WaitGroup
+	background.Add(3)
+	go func() {
+		defer background.Done()
+		workers.DefaultScheduler.Start(ctx)
+	}()
+	go func() {
+		defer background.Done()
+		queue.Start(ctx, 4)
+	}()
+	go func() {
+		defer background.Done()
+		events.StartRelay(ctx)
+	}()
+	backgroundDone := make(chan struct{})
+	go func() {
+		background.Wait()
+		close(backgroundDone)
+	}()
+
+	srv := &http.Server{
+		Addr:    ":8080",
+		Handler: routes.SetupRouter(),
+	}
+	go func() {
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Fatalf("Server failed: %v", err)
+		}
+	}()
+
+	<-ctx.Done()
+	log.Println("Shutting down...")
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		log.Printf("Server shutdown failed: %v", err)
+	}
+
+	// Wait for running jobs to notice the cancellation
+	select {
+	case <-backgroundDone:
+	case <-shutdownCtx.Done():
+		log.Println("Timed out waiting for background jobs")
+	}
 }
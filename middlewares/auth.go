@@ -35,10 +35,34 @@ func AuthMiddleware() gin.HandlerFunc {
 		}
 
 		// Add claims to context
-		if claims, ok := token.Claims.(jwt.MapClaims); ok {
-			c.Set("claims", claims)
+		claims, ok := token.Claims.(jwt.MapClaims)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+		c.Set("claims", claims)
+
+		// Load the caller's current role, so role changes apply immediately
+		if !loadRole(c, claims) {
+			c.Abort()
+			return
 		}
 
 		c.Next()
 	}
 }
+
+// QueryTokenAuth lets clients that cannot set headers, such as browser
+// WebSockets, pass the JWT as an access_token query parameter. It must run
+// before AuthMiddleware, which then validates the token as usual.
+func QueryTokenAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetHeader("Authorization") == "" {
+			if token := c.Query("access_token"); token != "" {
+				c.Request.Header.Set("Authorization", "Bearer "+token)
+			}
+		}
+		c.Next()
+	}
+}
@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"net/http"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/sessions"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// loadRole stores the role of the user named by the token in the context,
+// responding with an error if the user no longer exists or is disabled, or
+// the token has been revoked
+func loadRole(c *gin.Context, claims jwt.MapClaims) bool {
+	userID, ok := claims["user_id"].(float64)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return false
+	}
+
+	var user models.User
+	if err := config.DB.Preload("Role.Permissions").First(&user, uint(userID)).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not found"})
+		return false
+	}
+	if !user.IsActive {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Account is disabled"})
+		return false
+	}
+	// Tokens issued before the user's role changed or they logged out of all
+	// sessions are no longer valid
+	version, _ := claims["token_version"].(float64)
+	if int(version) != user.TokenVersion {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+		return false
+	}
+	// Tokens revoked by logging out
+	if jti, _ := claims["jti"].(string); jti != "" {
+		revoked, err := sessions.IsRevoked(config.DB, jti)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error"})
+			return false
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Token has been revoked"})
+			return false
+		}
+	}
+
+	c.Set("role", &user.Role)
+	return true
+}
+
+// RequirePermission rejects requests from users whose role lacks the
+// permission. It must run after AuthMiddleware.
+func RequirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Can(c, permission) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "You do not have permission to do this", "permission": permission})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// RequireAdmin rejects requests from users whose role is not an admin role.
+// It guards what could otherwise be used to gain permissions, such as
+// editing roles. It must run after AuthMiddleware.
+func RequireAdmin() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if role := CurrentRole(c); role == nil || !role.IsAdmin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can do this"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// Can reports whether the caller's role grants the permission
+func Can(c *gin.Context, permission string) bool {
+	role := CurrentRole(c)
+	return role != nil && role.Has(permission)
+}
+
+// CurrentRole returns the caller's role loaded by AuthMiddleware
+func CurrentRole(c *gin.Context) *models.Role {
+	if role, ok := c.Get("role"); ok {
+		return role.(*models.Role)
+	}
+	return nil
+}
@@ -0,0 +1,15 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// StorageKeys converts file paths saved before the storage drivers were
+// introduced ("uploads/<name>") into keys relative to the storage root
+func StorageKeys(db *gorm.DB) error {
+	return db.Exec(`UPDATE files SET file_path = substr(file_path, length('uploads/') + 1) WHERE file_path LIKE 'uploads/%'`).Error
+}
+
+// FileVersions makes each file uploaded before versioning the first version
+// of its own chain
+func FileVersions(db *gorm.DB) error {
+	return db.Exec(`UPDATE files SET origin_id = id WHERE origin_id IS NULL OR origin_id = 0`).Error
+}
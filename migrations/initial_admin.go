@@ -0,0 +1,40 @@
+package migrations
+
+import (
+	"errors"
+	"os"
+
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/utils"
+	"gorm.io/gorm"
+)
+
+// InitAdmin creates an administrator from ADMIN_USERNAME and ADMIN_PASSWORD
+// if no user has that name yet. Self-registration only grants the default
+// role, so this is how the first admin is created.
+func InitAdmin(db *gorm.DB) error {
+	username, password := os.Getenv("ADMIN_USERNAME"), os.Getenv("ADMIN_PASSWORD")
+	if username == "" || password == "" {
+		return nil
+	}
+
+	var existing models.User
+	err := db.Where("user_name = ?", username).First(&existing).Error
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+
+	var role models.Role
+	if err := db.Where("role = ?", "admin").First(&role).Error; err != nil {
+		return err
+	}
+	hashed, err := utils.HashPassword(password)
+	if err != nil {
+		return err
+	}
+	// Email must be unique, so the username stands in for it
+	return db.Create(&models.User{UserName: username, Email: username, Password: hashed, RoleID: role.ID}).Error
+}
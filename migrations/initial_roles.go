@@ -36,5 +36,16 @@ func InitRoles(db *gorm.DB) error {
 			return err
 		}
 	}
+	return InitPermissions(db)
+}
+
+// InitPermissions creates the permissions that can be granted to roles
+func InitPermissions(db *gorm.DB) error {
+	for name, description := range models.Permissions {
+		permission := models.Permission{Name: name, Description: description}
+		if err := db.FirstOrCreate(&permission, models.Permission{Name: name}).Error; err != nil {
+			return err
+		}
+	}
 	return nil
 }
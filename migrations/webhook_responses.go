@@ -0,0 +1,9 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// WebhookResponses drops the response bodies that deliveries used to record,
+// which exposed whatever the webhook URL returned to the user who chose it
+func WebhookResponses(db *gorm.DB) error {
+	return db.Exec(`ALTER TABLE webhook_deliveries DROP COLUMN IF EXISTS response_body`).Error
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// Blob is file content stored once under its SHA-256 hash and shared by every
+// File with identical content
+type Blob struct {
+	Hash      string    `gorm:"primaryKey;size:64" json:"hash"`
+	Size      int64     `json:"size"`
+	RefCount  int       `gorm:"not null;default:0" json:"ref_count"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// Email delivery statuses
+const (
+	EmailPending = "pending"
+	EmailSent    = "sent"
+	EmailFailed  = "failed"
+)
+
+// EmailDelivery is an outgoing email, kept so failed sends can be retried
+type EmailDelivery struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	UserID        uint       `json:"user_id" gorm:"index"`
+	To            string     `json:"to" gorm:"size:100;not null"`
+	Subject       string     `json:"subject" gorm:"size:255;not null"`
+	TextBody      string     `json:"-" gorm:"type:text"`
+	HTMLBody      string     `json:"-" gorm:"type:text"`
+	Status        string     `json:"status" gorm:"size:20;index;default:'pending'"`
+	Attempts      int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastError     string     `json:"last_error" gorm:"size:1000"`
+	SentAt        *time.Time `json:"sent_at"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
@@ -2,9 +2,26 @@ package models
 
 import "time"
 
+// Virus scan states of a file. Only clean files can be downloaded.
+const (
+	ScanPending  = "pending_scan"
+	ScanClean    = "clean"
+	ScanInfected = "infected"
+	ScanError    = "error"     // Retried hourly
+	ScanTooLarge = "too_large" // Exceeds the scanner's size limit; not retried
+)
+
+// Kinds of preview generated for a file
+const (
+	PreviewNone  = "none"
+	PreviewImage = "image" // Thumbnails in each of files.ThumbnailSizes
+	PreviewText  = "text"
+)
+
 type File struct {
 	ID         uint      `gorm:"primaryKey"`
-	FilePath   string    `gorm:"not null"`
+	FilePath   string    `gorm:"not null"` // Storage key of the contents
+	BlobHash   string    `gorm:"size:64;index"`
 	FileName   string    `gorm:"not null"`
 	FileType   string    `gorm:"size:100"`
 	FileSize   int64     `gorm:"default:0"`
@@ -12,6 +29,16 @@ type File struct {
 	UserID     uint      `gorm:"index"`
 	UploadedAt time.Time `gorm:"autoCreateTime"`
 
+	// Versions of the same file share OriginID, the ID of the first version
+	OriginID  uint `gorm:"index:idx_files_origin_version,unique;default:null"`
+	Version   int  `gorm:"index:idx_files_origin_version,unique;default:1"`
+	IsCurrent bool `gorm:"default:true"`
+
+	Preview    string `gorm:"size:10"` // Empty until previews have been generated
+	ScanStatus string `gorm:"size:20;default:'pending_scan';index"`
+	ScanResult string // Detected signature, or the error of the last attempt
+	ScannedAt  *time.Time
+
 	// Relationships
 	Task Task `gorm:"foreignKey:TaskID"`
 	User User `gorm:"foreignKey:UserID"`
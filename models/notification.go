@@ -2,14 +2,26 @@ package models
 
 import "time"
 
+// Notification types
+const (
+	NotificationComment      = "comment"
+	NotificationStatusChange = "status_change"
+	NotificationFileUpload   = "file_upload"
+	NotificationDueDate      = "due_date"
+	NotificationFileScan     = "file_scan"
+)
+
+var NotificationTypes = []string{NotificationComment, NotificationStatusChange, NotificationFileUpload, NotificationDueDate, NotificationFileScan}
+
 type Notification struct {
-	ID        uint      `gorm:"primaryKey"`
-	Message   string    `gorm:"not null;size:1000"`
-	Status    string    `gorm:"type:enum('unread','read');default:'unread'"`
-	UserID    uint      `gorm:"index"`
-	TaskID    uint      `gorm:"index"`
-	Type      string    `gorm:"type:enum('comment','status_change','file_upload','due_date');not null"`
-	CreatedAt time.Time `gorm:"autoCreateTime"`
+	ID        uint       `gorm:"primaryKey"`
+	Message   string     `gorm:"not null;size:1000"`
+	Status    string     `gorm:"type:enum('unread','read');default:'unread';index:idx_notifications_user_status,priority:2"`
+	UserID    uint       `gorm:"index;index:idx_notifications_user_status,priority:1"`
+	TaskID    uint       `gorm:"index"`
+	Type      string     `gorm:"type:enum('comment','status_change','file_upload','due_date','file_scan');not null"`
+	CreatedAt time.Time  `gorm:"autoCreateTime"`
+	EmailedAt *time.Time `json:"-" gorm:"index"` // Set once sent individually or in a digest
 
 	// Relationships
 	User User `gorm:"foreignKey:UserID"`
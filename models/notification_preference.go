@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Delivery channels a user can pick per notification type
+const (
+	ChannelInApp   = "in_app"
+	ChannelEmail   = "email"
+	ChannelWebhook = "webhook"
+	ChannelOff     = "off"
+)
+
+var NotificationChannels = []string{ChannelInApp, ChannelEmail, ChannelWebhook, ChannelOff}
+
+// NotificationPreference chooses how a user receives one type of notification.
+// Types without a row are delivered in-app.
+type NotificationPreference struct {
+	ID      uint   `json:"-" gorm:"primaryKey"`
+	UserID  uint   `json:"-" gorm:"uniqueIndex:idx_notification_preference;not null"`
+	Type    string `json:"type" gorm:"uniqueIndex:idx_notification_preference;size:50;not null"`
+	Channel string `json:"channel" gorm:"size:20;not null;default:'in_app'"`
+}
+
+// Email digest modes
+const (
+	DigestOff    = "off" // Send each notification as it happens
+	DigestHourly = "hourly"
+	DigestDaily  = "daily"
+)
+
+var DigestModes = []string{DigestOff, DigestHourly, DigestDaily}
+
+// NotificationSettings holds a user's quiet hours and email digest mode.
+// During quiet hours notifications are still recorded but not pushed, and
+// email and webhook delivery is held until the quiet hours end.
+type NotificationSettings struct {
+	UserID          uint       `json:"-" gorm:"primaryKey"`
+	QuietHoursStart string     `json:"quiet_hours_start" gorm:"size:5"` // HH:MM, empty disables quiet hours
+	QuietHoursEnd   string     `json:"quiet_hours_end" gorm:"size:5"`   // HH:MM
+	TimeZone        string     `json:"time_zone" gorm:"size:64;default:'UTC'"`
+	EmailDigest     string     `json:"email_digest" gorm:"size:10;default:'off'"`
+	LastDigestAt    *time.Time `json:"-"`
+}
+
+// TaskMute silences all notifications about a task for one user
+type TaskMute struct {
+	ID        uint      `json:"-" gorm:"primaryKey"`
+	UserID    uint      `json:"-" gorm:"uniqueIndex:idx_task_mute;not null"`
+	TaskID    uint      `json:"task_id" gorm:"uniqueIndex:idx_task_mute;not null"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
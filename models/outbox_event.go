@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// OutboxEvent is a domain event written in the same transaction as the change
+// it describes, and published to subscribers afterwards by the outbox relay
+type OutboxEvent struct {
+	ID            uint   `gorm:"primaryKey"`
+	Type          string `gorm:"size:100;not null"`
+	TaskID        uint   `gorm:"index"`
+	OwnerID       uint
+	ActorID       uint
+	Payload       string     `gorm:"type:text"`
+	OccurredAt    time.Time  `gorm:"not null"`
+	PublishedAt   *time.Time `gorm:"index"`
+	Attempts      int        `gorm:"default:0"`
+	NextAttemptAt time.Time
+	LastError     string     `gorm:"size:1000"`
+	Handled       string     `gorm:"size:1000"` // Comma-separated names of the subscribers that succeeded
+	FailedAt      *time.Time // Set when the relay gave up after its maximum number of attempts
+}
@@ -0,0 +1,30 @@
+package models
+
+// Permission is a capability granted to roles in addition to the Role flags
+type Permission struct {
+	ID          uint   `gorm:"primaryKey" json:"id"`
+	Name        string `gorm:"unique;not null;size:100" json:"name"`
+	Description string `json:"description"`
+}
+
+// Permissions checked by the authorizer. The first four are granted by the
+// Role flags; the rest are granted through Role.Permissions. Admin roles have
+// every permission.
+const (
+	PermissionManageTasks    = "tasks.manage"    // Create, edit and delete own tasks
+	PermissionManageComments = "comments.manage" // Add and delete comments
+	PermissionManageFiles    = "files.manage"    // Upload, restore and delete files
+	PermissionManageUsers    = "users.manage"    // List and delete users and set their quotas
+
+	PermissionAllTasks      = "tasks.all"      // Act on any user's tasks, comments and files
+	PermissionManageJobs    = "jobs.manage"    // View, run and retry background jobs
+	PermissionManageStorage = "storage.manage" // Check and repair file storage
+)
+
+// Permissions lists the permissions stored in the permissions table, with
+// their descriptions
+var Permissions = map[string]string{
+	PermissionAllTasks:      "Act on any user's tasks, comments and files",
+	PermissionManageJobs:    "View, run and retry background jobs",
+	PermissionManageStorage: "Check and repair file storage",
+}
@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Queued job statuses
+const (
+	JobPending   = "pending"
+	JobRunning   = "running"
+	JobSucceeded = "succeeded"
+	JobFailed    = "failed" // Gave up after MaxAttempts; can be retried by an admin
+)
+
+// QueuedJob is a unit of asynchronous work in the Postgres-backed job queue
+type QueuedJob struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Type        string     `json:"type" gorm:"size:100;not null;index"`
+	Payload     string     `json:"payload" gorm:"type:text"`
+	Status      string     `json:"status" gorm:"size:20;not null;default:'pending';index:idx_queued_jobs_status_run_at,priority:1"`
+	Attempts    int        `json:"attempts" gorm:"default:0"`
+	MaxAttempts int        `json:"max_attempts" gorm:"default:5"`
+	RunAt       time.Time  `json:"run_at" gorm:"not null;index:idx_queued_jobs_status_run_at,priority:2"`
+	LockedUntil *time.Time `json:"locked_until"` // Visibility timeout while running
+	LastError   string     `json:"last_error" gorm:"size:1000"`
+	FinishedAt  *time.Time `json:"finished_at"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,11 @@
+package models
+
+import "time"
+
+// RealtimePayload is a real-time message too large to send through Postgres
+// NOTIFY. The notification carries its ID and each server instance loads it.
+type RealtimePayload struct {
+	ID        uint      `gorm:"primaryKey"`
+	Payload   string    `gorm:"type:text;not null"`
+	CreatedAt time.Time `gorm:"index"`
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+// RefreshToken is a single-use token for getting a new access token. Each
+// refresh replaces it with a new token of the same session (FamilyID), so a
+// token presented twice reveals that it was stolen.
+type RefreshToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	UserID    uint      `gorm:"index;not null"`
+	FamilyID  string    `gorm:"size:32;index;not null"` // Session ID, shared by every rotation
+	TokenHash string    `gorm:"size:64;uniqueIndex;not null"`
+	ExpiresAt time.Time `gorm:"index"`
+	UsedAt    *time.Time
+	RevokedAt *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken records an access token revoked before it expires. Rows can be
+// deleted once ExpiresAt has passed.
+type RevokedToken struct {
+	JTI       string    `gorm:"primaryKey;size:32"`
+	ExpiresAt time.Time `gorm:"index"`
+}
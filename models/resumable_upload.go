@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// ResumableUpload is a tus upload in progress. Received chunks are stored as
+// parts until the upload is complete, when they are combined into a File.
+type ResumableUpload struct {
+	ID        string    `gorm:"primaryKey;size:32" json:"id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	TaskID    uint      `json:"task_id"`
+	OriginID  uint      `json:"origin_id,omitempty"` // Set when uploading a new version of a file
+	FileName  string    `json:"file_name"`
+	Length    int64     `json:"length"`
+	Offset    int64     `gorm:"column:upload_offset;not null;default:0" json:"offset"`
+	FileID    *uint     `json:"file_id,omitempty"` // Set once the upload is complete
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	Parts []ResumableUploadPart `json:"-" gorm:"foreignKey:UploadID;constraint:OnDelete:CASCADE"`
+}
+
+// ResumableUploadPart is one stored chunk of a resumable upload
+type ResumableUploadPart struct {
+	ID       uint   `gorm:"primaryKey"`
+	UploadID string `gorm:"size:32;uniqueIndex:idx_upload_parts_offset"`
+	Offset   int64  `gorm:"column:part_offset;uniqueIndex:idx_upload_parts_offset"`
+	Size     int64
+	// Key is where the part is stored. Parts written before it was recorded
+	// are stored at a key derived from the upload ID and offset.
+	Key string `gorm:"size:255"`
+}
@@ -1,14 +1,61 @@
 package models
 
+import "sort"
+
+// DefaultRole is the role given to users who register themselves
+const DefaultRole = "user"
+
 type Role struct {
-	ID                uint   `gorm:"primaryKey"`
-	Role              string `gorm:"unique;not null"`
-	Permission        string
-	Name              string
-	Description       string
-	CanManageTasks    bool `gorm:"default:false"`
-	CanManageUsers    bool `gorm:"default:false"`
-	CanManageComments bool `gorm:"default:false"`
-	CanManageFiles    bool `gorm:"default:false"`
-	IsAdmin           bool `gorm:"default:false"`
+	ID                uint   `json:"id" gorm:"primaryKey"`
+	Role              string `json:"role" gorm:"unique;not null"`
+	Permission        string `json:"permission"`
+	Name              string `json:"name"`
+	Description       string `json:"description"`
+	CanManageTasks    bool   `json:"can_manage_tasks" gorm:"default:false"`
+	CanManageUsers    bool   `json:"can_manage_users" gorm:"default:false"`
+	CanManageComments bool   `json:"can_manage_comments" gorm:"default:false"`
+	CanManageFiles    bool   `json:"can_manage_files" gorm:"default:false"`
+	IsAdmin           bool   `json:"is_admin" gorm:"default:false"`
+
+	Permissions []Permission `json:"permissions" gorm:"many2many:role_permissions"`
+}
+
+// Has reports whether the role grants a permission
+func (r *Role) Has(permission string) bool {
+	if r.IsAdmin {
+		return true
+	}
+	switch permission {
+	case PermissionManageTasks:
+		return r.CanManageTasks
+	case PermissionManageComments:
+		return r.CanManageComments
+	case PermissionManageFiles:
+		return r.CanManageFiles
+	case PermissionManageUsers:
+		return r.CanManageUsers
+	}
+	for _, p := range r.Permissions {
+		if p.Name == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Granted lists every permission the role grants
+func (r *Role) Granted() []string {
+	names := []string{PermissionManageTasks, PermissionManageComments, PermissionManageFiles, PermissionManageUsers}
+	for name := range Permissions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	granted := []string{}
+	for _, name := range names {
+		if r.Has(name) {
+			granted = append(granted, name)
+		}
+	}
+	return granted
 }
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// ScheduledJob persists a background job's schedule state across restarts
+type ScheduledJob struct {
+	Name         string     `json:"name" gorm:"primaryKey;size:100"`
+	Schedule     string     `json:"schedule" gorm:"size:100;not null"`
+	LastRunAt    *time.Time `json:"last_run_at"`
+	NextRunAt    time.Time  `json:"next_run_at"`
+	LastDuration int64      `json:"last_duration_ms"`
+	LastError    string     `json:"last_error" gorm:"size:1000"`
+	Running      bool       `json:"running"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+// TaskReminder records that one stage of a task's due-date reminder schedule
+// was sent, so each stage goes out once. Rows are cleared when the due date changes.
+type TaskReminder struct {
+	ID     uint      `gorm:"primaryKey"`
+	TaskID uint      `gorm:"uniqueIndex:idx_task_reminder;not null"`
+	Stage  string    `gorm:"uniqueIndex:idx_task_reminder;size:50;not null"` // e.g. "before:24h0m0s" or "overdue:3"
+	SentAt time.Time `gorm:"autoCreateTime"`
+}
@@ -15,6 +15,14 @@ type User struct {
 	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 
+	// StorageQuota limits the bytes of attachments the user can upload. Nil
+	// uses the default quota and zero means unlimited.
+	StorageQuota *int64 `json:"storage_quota"`
+
+	// TokenVersion is embedded in issued tokens. Incrementing it invalidates
+	// every token issued before, such as when the user's role changes.
+	TokenVersion int `json:"-" gorm:"not null;default:0"`
+
 	// Relationships
 	Role          Role           `gorm:"foreignKey:RoleID"`
 	Tasks         []Task         `gorm:"foreignKey:UserID"`
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Webhook delivery statuses
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryDead      = "dead" // Gave up after the maximum number of attempts
+)
+
+// Webhook is a URL that receives signed JSON payloads for task, comment,
+// file and notification events
+type Webhook struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index"`
+	URL       string    `json:"url" gorm:"size:2048;not null"`
+	Secret    string    `json:"-" gorm:"size:255;not null"`
+	Events    string    `json:"events" gorm:"size:1000;not null"` // Comma-separated filters, e.g. "task.*,comment.created"
+	Active    bool      `json:"active" gorm:"default:true"`
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+
+	// Relationships
+	User User `json:"-" gorm:"foreignKey:UserID"`
+}
+
+// WebhookDelivery is one payload sent (or to be sent) to a webhook
+type WebhookDelivery struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	WebhookID      uint       `json:"webhook_id" gorm:"index"`
+	Event          string     `json:"event" gorm:"size:100;not null"`
+	Payload        string     `json:"payload" gorm:"type:text;not null"`
+	Status         string     `json:"status" gorm:"size:20;index;default:'pending'"`
+	Attempts       int        `json:"attempts" gorm:"default:0"`
+	NextAttemptAt  time.Time  `json:"next_attempt_at" gorm:"index"`
+	LastStatusCode int        `json:"last_status_code"`
+	LastError      string     `json:"last_error" gorm:"size:1000"`
+	DeliveredAt    *time.Time `json:"delivered_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"autoCreateTime"`
+
+	// Relationships
+	Webhook Webhook `json:"-" gorm:"foreignKey:WebhookID;constraint:OnDelete:CASCADE"`
+}
@@ -0,0 +1,216 @@
+package notifications
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/queue"
+	"github.com/Chamanthra/TaskManager/realtime"
+	"gorm.io/gorm"
+)
+
+// Deliverer sends a recorded notification over an external channel
+type Deliverer func(notification models.Notification) error
+
+var (
+	mu         sync.RWMutex
+	deliverers = map[string]Deliverer{}
+)
+
+// RegisterDeliverer installs the delivery function for a channel such as
+// email or webhook
+func RegisterDeliverer(channel string, deliverer Deliverer) {
+	mu.Lock()
+	defer mu.Unlock()
+	deliverers[channel] = deliverer
+}
+
+// Notify is the single entry point for notifying a user. It honours the user's
+// per-type channel preference, task mutes and quiet hours. It returns the
+// recorded notification, or nil if the user opted out of it.
+//
+// Email and webhook delivery is queued as a separate DeliverJob in the same
+// transaction as the notification, so a failed delivery is retried on its
+// own without recording the notification again.
+func Notify(userID, taskID uint, notificationType, message string) (*models.Notification, error) {
+	channel, err := channelFor(userID, notificationType)
+	if err != nil {
+		return nil, err
+	}
+	if channel == models.ChannelOff {
+		return nil, nil
+	}
+
+	if taskID != 0 {
+		var mutes int64
+		if err := config.DB.Model(&models.TaskMute{}).Where("user_id = ? AND task_id = ?", userID, taskID).Count(&mutes).Error; err != nil {
+			return nil, err
+		}
+		if mutes > 0 {
+			return nil, nil
+		}
+	}
+
+	// Notifications in quiet hours are recorded for the user to read later
+	// but not pushed, and external delivery waits until the quiet hours end
+	quietUntil, err := quietHoursEnd(userID, time.Now())
+	if err != nil {
+		log.Printf("notifications: failed to load settings for user %d: %v", userID, err)
+	}
+	quiet := !quietUntil.IsZero()
+
+	mu.RLock()
+	_, external := deliverers[channel]
+	mu.RUnlock()
+	external = external && channel != models.ChannelInApp
+
+	notification := models.Notification{
+		Message: message,
+		UserID:  userID,
+		TaskID:  taskID,
+		Type:    notificationType,
+	}
+	err = config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&notification).Error; err != nil {
+			return fmt.Errorf("failed to create notification: %w", err)
+		}
+		if !external {
+			return nil
+		}
+		runAt := time.Now()
+		if quiet {
+			runAt = quietUntil
+		}
+		return queue.EnqueueAt(tx, DeliverJob, deliverPayload{NotificationID: notification.ID, Channel: channel}, runAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !quiet && !external {
+		realtime.PublishNotification(notification)
+	}
+	return &notification, nil
+}
+
+// deliver sends a recorded notification over an external channel
+func deliver(notificationID uint, channel string) error {
+	mu.RLock()
+	deliverer, ok := deliverers[channel]
+	mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("no deliverer for channel %s", channel)
+	}
+
+	var notification models.Notification
+	err := config.DB.First(&notification, notificationID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil // Deleted by the user before it could be delivered
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := deliverer(notification); err != nil {
+		return fmt.Errorf("failed to deliver notification over %s: %w", channel, err)
+	}
+	return nil
+}
+
+// channelFor returns the user's chosen channel for a notification type
+func channelFor(userID uint, notificationType string) (string, error) {
+	var pref models.NotificationPreference
+	err := config.DB.Where("user_id = ? AND type = ?", userID, notificationType).First(&pref).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.ChannelInApp, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return pref.Channel, nil
+}
+
+// quietHoursEnd returns when the user's current quiet hours end, or the zero
+// time if now is outside them
+func quietHoursEnd(userID uint, now time.Time) (time.Time, error) {
+	var settings models.NotificationSettings
+	err := config.DB.First(&settings, "user_id = ?", userID).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if !InQuietHours(settings, now) {
+		return time.Time{}, nil
+	}
+	return QuietHoursEnd(settings, now), nil
+}
+
+// InQuietHours reports whether t falls inside the quiet hours window, which
+// may wrap past midnight (e.g. 22:00-07:00)
+func InQuietHours(settings models.NotificationSettings, t time.Time) bool {
+	start, end, loc, ok := quietHours(settings)
+	if !ok {
+		return false
+	}
+	local := t.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+
+	if start <= end {
+		return minute >= start && minute < end
+	}
+	return minute >= start || minute < end
+}
+
+// QuietHoursEnd returns the first end of the quiet hours window after t, or
+// t itself if the user has no quiet hours
+func QuietHoursEnd(settings models.NotificationSettings, t time.Time) time.Time {
+	_, end, loc, ok := quietHours(settings)
+	if !ok {
+		return t
+	}
+	local := t.In(loc)
+	next := time.Date(local.Year(), local.Month(), local.Day(), end/60, end%60, 0, 0, loc)
+	if !next.After(local) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// quietHours parses the window's start and end in minutes after midnight in
+// the user's time zone. ok is false when quiet hours are disabled or invalid.
+func quietHours(settings models.NotificationSettings) (start, end int, loc *time.Location, ok bool) {
+	if settings.QuietHoursStart == "" || settings.QuietHoursEnd == "" {
+		return 0, 0, nil, false
+	}
+
+	start, err := ParseClock(settings.QuietHoursStart)
+	if err != nil {
+		return 0, 0, nil, false
+	}
+	end, err = ParseClock(settings.QuietHoursEnd)
+	if err != nil {
+		return 0, 0, nil, false
+	}
+
+	loc, err = time.LoadLocation(settings.TimeZone)
+	if err != nil {
+		loc = time.UTC
+	}
+	return start, end, loc, true
+}
+
+// ParseClock converts "HH:MM" to minutes after midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
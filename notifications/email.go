@@ -0,0 +1,64 @@
+package notifications
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/mailer"
+	"github.com/Chamanthra/TaskManager/models"
+	"gorm.io/gorm"
+)
+
+// DeliverEmail queues a notification for email delivery. Users in digest mode
+// get it later as part of their digest instead.
+func DeliverEmail(notification models.Notification) error {
+	var settings models.NotificationSettings
+	err := config.DB.First(&settings, "user_id = ?", notification.UserID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if settings.EmailDigest == models.DigestHourly || settings.EmailDigest == models.DigestDaily {
+		return nil
+	}
+
+	var user models.User
+	if err := config.DB.First(&user, notification.UserID).Error; err != nil {
+		return err
+	}
+	if user.Email == "" {
+		return nil
+	}
+
+	item := mailer.Item{Message: notification.Message, CreatedAt: notification.CreatedAt}
+	var task models.Task
+	if notification.TaskID != 0 && config.DB.First(&task, notification.TaskID).Error == nil {
+		item.TaskTitle = task.Title
+	}
+
+	msg, err := mailer.RenderNotification(user.Email, user.UserName, item)
+	if err != nil {
+		return err
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if err := QueueEmail(tx, user.ID, msg); err != nil {
+			return err
+		}
+		return tx.Model(&notification).Update("emailed_at", time.Now()).Error
+	})
+}
+
+// QueueEmail stores an email for the email worker to send
+func QueueEmail(tx *gorm.DB, userID uint, msg mailer.Message) error {
+	delivery := models.EmailDelivery{
+		UserID:        userID,
+		To:            msg.To,
+		Subject:       msg.Subject,
+		TextBody:      msg.Text,
+		HTMLBody:      msg.HTML,
+		Status:        models.EmailPending,
+		NextAttemptAt: time.Now(),
+	}
+	return tx.Create(&delivery).Error
+}
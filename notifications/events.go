@@ -0,0 +1,23 @@
+package notifications
+
+import (
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/events"
+	"github.com/Chamanthra/TaskManager/models"
+)
+
+// HandleEvent queues a notification for the task owner when someone else
+// comments on or uploads a file to their task
+func HandleEvent(event events.Event) error {
+	if event.ActorID == event.OwnerID { // Don't notify yourself
+		return nil
+	}
+
+	switch event.Type {
+	case events.CommentCreated:
+		return Enqueue(config.DB, event.OwnerID, event.TaskID, models.NotificationComment, "New comment added to your task")
+	case events.FileUploaded:
+		return Enqueue(config.DB, event.OwnerID, event.TaskID, models.NotificationFileUpload, "New file uploaded to your task")
+	}
+	return nil
+}
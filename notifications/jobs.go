@@ -0,0 +1,52 @@
+package notifications
+
+import (
+	"context"
+
+	"github.com/Chamanthra/TaskManager/queue"
+	"gorm.io/gorm"
+)
+
+const (
+	// DispatchJob is the queue job type that records a notification
+	DispatchJob = "notification.dispatch"
+	// DeliverJob is the queue job type that sends a recorded notification by
+	// email or webhook
+	DeliverJob = "notification.deliver"
+)
+
+// dispatchPayload is the payload of a DispatchJob
+type dispatchPayload struct {
+	UserID  uint   `json:"user_id"`
+	TaskID  uint   `json:"task_id"`
+	Type    string `json:"type"`
+	Message string `json:"message"`
+}
+
+// deliverPayload is the payload of a DeliverJob
+type deliverPayload struct {
+	NotificationID uint   `json:"notification_id"`
+	Channel        string `json:"channel"`
+}
+
+// RegisterJobs installs the notification job handlers on the queue
+func RegisterJobs() {
+	queue.Handle(DispatchJob, func(ctx context.Context, p dispatchPayload) error {
+		_, err := Notify(p.UserID, p.TaskID, p.Type, p.Message)
+		return err
+	})
+	queue.Handle(DeliverJob, func(ctx context.Context, p deliverPayload) error {
+		return deliver(p.NotificationID, p.Channel)
+	})
+}
+
+// Enqueue queues a notification to be recorded and delivered in the
+// background, with retries if either fails
+func Enqueue(db *gorm.DB, userID, taskID uint, notificationType, message string) error {
+	return queue.Enqueue(db, DispatchJob, dispatchPayload{
+		UserID:  userID,
+		TaskID:  taskID,
+		Type:    notificationType,
+		Message: message,
+	})
+}
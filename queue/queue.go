@@ -0,0 +1,247 @@
+package queue
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"gorm.io/gorm"
+)
+
+const (
+	// VisibilityTimeout is how long a worker may hold a job before another
+	// worker assumes it died and picks the job up again
+	VisibilityTimeout = 5 * time.Minute
+
+	defaultMaxAttempts = 5
+	maxBackoff         = time.Hour
+	pollInterval       = time.Second
+)
+
+// Handler processes the raw JSON payload of one job type
+type Handler func(ctx context.Context, payload json.RawMessage) error
+
+var (
+	mu       sync.RWMutex
+	handlers = map[string]Handler{}
+)
+
+// Register installs the handler for a job type
+func Register(jobType string, handler Handler) {
+	mu.Lock()
+	defer mu.Unlock()
+	handlers[jobType] = handler
+}
+
+// Handle registers a handler whose payload is decoded into T
+func Handle[T any](jobType string, fn func(ctx context.Context, payload T) error) {
+	Register(jobType, func(ctx context.Context, raw json.RawMessage) error {
+		var payload T
+		if err := json.Unmarshal(raw, &payload); err != nil {
+			return fmt.Errorf("invalid %s payload: %w", jobType, err)
+		}
+		return fn(ctx, payload)
+	})
+}
+
+// Enqueue adds a job. Pass a transaction as db to enqueue atomically with
+// other writes.
+func Enqueue(db *gorm.DB, jobType string, payload interface{}) error {
+	return EnqueueAt(db, jobType, payload, time.Now())
+}
+
+// EnqueueAt adds a job that does not run before runAt
+func EnqueueAt(db *gorm.DB, jobType string, payload interface{}, runAt time.Time) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	job := models.QueuedJob{
+		Type:        jobType,
+		Payload:     string(data),
+		Status:      models.JobPending,
+		MaxAttempts: defaultMaxAttempts,
+		RunAt:       runAt,
+	}
+	return db.Create(&job).Error
+}
+
+// Retry resets a failed job so it runs again with a fresh set of attempts
+func Retry(id uint) error {
+	result := config.DB.Model(&models.QueuedJob{}).
+		Where("id = ? AND status = ?", id, models.JobFailed).
+		Updates(map[string]interface{}{
+			"status":       models.JobPending,
+			"attempts":     0,
+			"run_at":       time.Now(),
+			"locked_until": nil,
+			"finished_at":  nil,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// Start runs workers goroutines that process jobs until ctx is cancelled.
+// Every server instance can run workers; FOR UPDATE SKIP LOCKED ensures a
+// job is claimed by only one of them at a time.
+func Start(ctx context.Context, workers int) {
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			work(ctx)
+		}()
+	}
+	wg.Wait()
+}
+
+func work(ctx context.Context) {
+	for ctx.Err() == nil {
+		job, err := claim(ctx)
+		if err != nil {
+			if ctx.Err() == nil {
+				log.Printf("queue: failed to claim job: %v", err)
+			}
+		}
+		if job == nil {
+			failAbandoned(ctx)
+			select {
+			case <-ctx.Done():
+			case <-time.After(pollInterval):
+			}
+			continue
+		}
+
+		process(ctx, job)
+	}
+}
+
+// claim locks the next due job, including running jobs whose visibility
+// timeout has expired
+func claim(ctx context.Context) (*models.QueuedJob, error) {
+	var jobs []models.QueuedJob
+	err := config.DB.WithContext(ctx).Raw(`
+		UPDATE queued_jobs
+		SET status = ?, attempts = attempts + 1, locked_until = ?, updated_at = ?
+		WHERE id = (
+			SELECT id FROM queued_jobs
+			WHERE (status = ? AND run_at <= ?) OR (status = ? AND locked_until < ? AND attempts < max_attempts)
+			ORDER BY run_at
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`,
+		models.JobRunning, time.Now().Add(VisibilityTimeout), time.Now(),
+		models.JobPending, time.Now(), models.JobRunning, time.Now(),
+	).Scan(&jobs).Error
+	if err != nil || len(jobs) == 0 {
+		return nil, err
+	}
+	return &jobs[0], nil
+}
+
+// failAbandoned gives up on jobs whose worker died on the final attempt, so a
+// job that crashes the process cannot be retried forever
+func failAbandoned(ctx context.Context) {
+	err := config.DB.WithContext(ctx).Model(&models.QueuedJob{}).
+		Where("status = ? AND locked_until < ? AND attempts >= max_attempts", models.JobRunning, time.Now()).
+		Updates(map[string]interface{}{
+			"status":       models.JobFailed,
+			"locked_until": nil,
+			"finished_at":  time.Now(),
+			"last_error":   "visibility timeout expired on final attempt",
+		}).Error
+	if err != nil && ctx.Err() == nil {
+		log.Printf("queue: failed to expire abandoned jobs: %v", err)
+	}
+}
+
+// process runs a claimed job and records the outcome, retrying with
+// exponential backoff until MaxAttempts is reached
+func process(ctx context.Context, job *models.QueuedJob) {
+	err := run(ctx, job)
+
+	updates := map[string]interface{}{"locked_until": nil}
+	now := time.Now()
+	switch {
+	case err == nil:
+		updates["status"] = models.JobSucceeded
+		updates["finished_at"] = now
+		updates["last_error"] = ""
+	case job.Attempts >= job.MaxAttempts:
+		updates["status"] = models.JobFailed
+		updates["finished_at"] = now
+		updates["last_error"] = truncate(err.Error())
+		log.Printf("queue: job %d (%s) failed permanently: %v", job.ID, job.Type, err)
+	default:
+		updates["status"] = models.JobPending
+		updates["run_at"] = now.Add(backoff(job.Attempts))
+		updates["last_error"] = truncate(err.Error())
+	}
+
+	// Record the outcome even if shutdown has begun
+	if err := config.DB.Model(job).Updates(updates).Error; err != nil {
+		log.Printf("queue: failed to record result of job %d: %v", job.ID, err)
+	}
+}
+
+func run(ctx context.Context, job *models.QueuedJob) (err error) {
+	mu.RLock()
+	handler, ok := handlers[job.Type]
+	mu.RUnlock()
+	if !ok {
+		return errors.New("no handler registered for job type " + job.Type)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, VisibilityTimeout)
+	defer cancel()
+	ctx = context.WithValue(ctx, finalAttemptKey{}, job.Attempts >= job.MaxAttempts)
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			log.Printf("queue: job %d (%s) panicked: %v\n%s", job.ID, job.Type, r, debug.Stack())
+		}
+	}()
+
+	return handler(ctx, json.RawMessage(job.Payload))
+}
+
+type finalAttemptKey struct{}
+
+// IsFinalAttempt reports whether the job running with ctx will be given up on
+// if it fails, so handlers can record a permanent outcome
+func IsFinalAttempt(ctx context.Context) bool {
+	final, _ := ctx.Value(finalAttemptKey{}).(bool)
+	return final
+}
+
+// backoff doubles the wait after each attempt, starting at ten seconds
+func backoff(attempts int) time.Duration {
+	d := 10 * time.Second << (attempts - 1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
+
+func truncate(s string) string {
+	if len(s) > 1000 {
+		return s[:1000]
+	}
+	return s
+}
@@ -0,0 +1,93 @@
+package realtime
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+)
+
+// Message is a single event published on a topic
+type Message struct {
+	Topic string          `json:"topic"`
+	ID    string          `json:"id,omitempty"`
+	Event string          `json:"event"`
+	Data  json.RawMessage `json:"data"`
+}
+
+// Broker fans messages out to every subscriber of a topic
+type Broker interface {
+	Publish(msg Message) error
+	Subscribe(topic string) (<-chan Message, func())
+}
+
+// DefaultBroker is used by the controllers and workers to publish events
+var DefaultBroker Broker = NewMemoryBroker()
+
+// Setup selects the broker implementation from the NOTIFICATION_BROKER
+// environment variable ("memory" or "postgres")
+func Setup() error {
+	switch os.Getenv("NOTIFICATION_BROKER") {
+	case "postgres":
+		broker, err := NewPostgresBroker()
+		if err != nil {
+			return err
+		}
+		DefaultBroker = broker
+	default:
+		DefaultBroker = NewMemoryBroker()
+	}
+	return nil
+}
+
+// subscriberBuffer is how many messages a slow subscriber may fall behind
+// before further messages are dropped for it
+const subscriberBuffer = 32
+
+// MemoryBroker is an in-process broker, suitable for a single server instance
+type MemoryBroker struct {
+	mu     sync.RWMutex
+	topics map[string]map[chan Message]struct{}
+}
+
+func NewMemoryBroker() *MemoryBroker {
+	return &MemoryBroker{topics: make(map[string]map[chan Message]struct{})}
+}
+
+func (b *MemoryBroker) Publish(msg Message) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for ch := range b.topics[msg.Topic] {
+		select {
+		case ch <- msg:
+		default:
+			// Subscriber is not keeping up; it can catch up from the database
+		}
+	}
+	return nil
+}
+
+func (b *MemoryBroker) Subscribe(topic string) (<-chan Message, func()) {
+	ch := make(chan Message, subscriberBuffer)
+
+	b.mu.Lock()
+	if b.topics[topic] == nil {
+		b.topics[topic] = make(map[chan Message]struct{})
+	}
+	b.topics[topic][ch] = struct{}{}
+	b.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			delete(b.topics[topic], ch)
+			if len(b.topics[topic]) == 0 {
+				delete(b.topics, topic)
+			}
+			b.mu.Unlock()
+			close(ch)
+		})
+	}
+	return ch, unsubscribe
+}
@@ -0,0 +1,34 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/Chamanthra/TaskManager/models"
+)
+
+// NotificationTopic is the topic a user's notifications are published on
+func NotificationTopic(userID uint) string {
+	return fmt.Sprintf("notifications:%d", userID)
+}
+
+// PublishNotification pushes a newly created notification to its recipient
+func PublishNotification(notification models.Notification) {
+	data, err := json.Marshal(notification)
+	if err != nil {
+		log.Printf("realtime: failed to encode notification %d: %v", notification.ID, err)
+		return
+	}
+
+	msg := Message{
+		Topic: NotificationTopic(notification.UserID),
+		ID:    strconv.FormatUint(uint64(notification.ID), 10),
+		Event: "notification",
+		Data:  data,
+	}
+	if err := DefaultBroker.Publish(msg); err != nil {
+		log.Printf("realtime: failed to publish notification %d: %v", notification.ID, err)
+	}
+}
@@ -0,0 +1,139 @@
+package realtime
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/jackc/pgx/v5"
+)
+
+const (
+	// pgChannel is the LISTEN/NOTIFY channel shared by all server instances
+	pgChannel = "taskmanager_events"
+	// maxNotifyPayload leaves headroom below the 8000 bytes Postgres accepts
+	// in a notification
+	maxNotifyPayload = 7000
+	// payloadRetention is how long stored payloads are kept for instances to
+	// load them
+	payloadRetention = time.Hour
+)
+
+// envelope is what is sent through NOTIFY. Messages too large for it are
+// stored as a RealtimePayload and only their ID is sent.
+type envelope struct {
+	Message
+	PayloadID uint `json:"payload_id,omitempty"`
+}
+
+// PostgresBroker relays messages through Postgres LISTEN/NOTIFY so that
+// subscribers connected to any server instance receive them
+type PostgresBroker struct {
+	local *MemoryBroker
+}
+
+func NewPostgresBroker() (*PostgresBroker, error) {
+	conn, err := pgx.Connect(context.Background(), config.DSN())
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Exec(context.Background(), "LISTEN "+pgChannel); err != nil {
+		conn.Close(context.Background())
+		return nil, err
+	}
+
+	broker := &PostgresBroker{local: NewMemoryBroker()}
+	go broker.listen(conn)
+	return broker, nil
+}
+
+func (b *PostgresBroker) Publish(msg Message) error {
+	payload, err := json.Marshal(envelope{Message: msg})
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxNotifyPayload {
+		stored := models.RealtimePayload{Payload: string(payload)}
+		if err := config.DB.Create(&stored).Error; err != nil {
+			return fmt.Errorf("failed to store large message: %w", err)
+		}
+		small := envelope{Message: Message{Topic: msg.Topic, ID: msg.ID, Event: msg.Event}, PayloadID: stored.ID}
+		if payload, err = json.Marshal(small); err != nil {
+			return err
+		}
+	}
+	return config.DB.Exec("SELECT pg_notify(?, ?)", pgChannel, string(payload)).Error
+}
+
+func (b *PostgresBroker) Subscribe(topic string) (<-chan Message, func()) {
+	return b.local.Subscribe(topic)
+}
+
+// listen forwards notifications to local subscribers, reconnecting if the
+// connection drops
+func (b *PostgresBroker) listen(conn *pgx.Conn) {
+	ctx := context.Background()
+	for {
+		notification, err := conn.WaitForNotification(ctx)
+		if err != nil {
+			log.Printf("realtime: lost LISTEN connection: %v", err)
+			conn.Close(ctx)
+			conn = b.reconnect(ctx)
+			continue
+		}
+
+		var msg envelope
+		if err := json.Unmarshal([]byte(notification.Payload), &msg); err != nil {
+			log.Printf("realtime: invalid notification payload: %v", err)
+			continue
+		}
+		if msg.PayloadID != 0 {
+			if err := loadPayload(msg.PayloadID, &msg); err != nil {
+				log.Printf("realtime: failed to load %s message %d: %v", msg.Event, msg.PayloadID, err)
+				continue
+			}
+		}
+		b.local.Publish(msg.Message)
+	}
+}
+
+// loadPayload reads a message that was too large to be sent through NOTIFY
+func loadPayload(id uint, msg *envelope) error {
+	var stored models.RealtimePayload
+	if err := config.DB.First(&stored, id).Error; err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(stored.Payload), msg)
+}
+
+// PruneStoredPayloads deletes large messages old enough that every instance
+// has loaded them
+func PruneStoredPayloads(ctx context.Context) error {
+	err := config.DB.WithContext(ctx).
+		Where("created_at < ?", time.Now().Add(-payloadRetention)).
+		Delete(&models.RealtimePayload{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to prune real-time payloads: %w", err)
+	}
+	return nil
+}
+
+func (b *PostgresBroker) reconnect(ctx context.Context) *pgx.Conn {
+	for {
+		time.Sleep(2 * time.Second)
+
+		conn, err := pgx.Connect(ctx, config.DSN())
+		if err != nil {
+			continue
+		}
+		if _, err := conn.Exec(ctx, "LISTEN "+pgChannel); err != nil {
+			conn.Close(ctx)
+			continue
+		}
+		return conn
+	}
+}
@@ -0,0 +1,54 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+// SessionRevokedEvent announces that sessions of a user have ended, so
+// connections authenticated by them must be closed
+const SessionRevokedEvent = "session.revoked"
+
+type sessionRevoked struct {
+	SessionID string `json:"session_id,omitempty"` // Empty when every session ended
+}
+
+// SessionsTopic is the topic the revocation of a user's sessions is
+// published on
+func SessionsTopic(userID uint) string {
+	return fmt.Sprintf("sessions:%d", userID)
+}
+
+// PublishSessionRevoked announces that a session of the user has ended, or
+// all of them if sessionID is empty
+func PublishSessionRevoked(userID uint, sessionID string) {
+	data, _ := json.Marshal(sessionRevoked{SessionID: sessionID})
+	msg := Message{Topic: SessionsTopic(userID), Event: SessionRevokedEvent, Data: data}
+	if err := DefaultBroker.Publish(msg); err != nil {
+		log.Printf("realtime: failed to publish revocation of user %d's sessions: %v", userID, err)
+	}
+}
+
+// WatchSession returns a channel that is closed when the user's session is
+// revoked, for closing long-lived connections it authenticated. Tokens from
+// before sessions existed have no session ID and are only closed when all
+// sessions are revoked. Call stop when the connection ends.
+func WatchSession(userID uint, sessionID string) (revoked <-chan struct{}, stop func()) {
+	messages, unsubscribe := DefaultBroker.Subscribe(SessionsTopic(userID))
+	done := make(chan struct{})
+	go func() {
+		for msg := range messages {
+			var revocation sessionRevoked
+			if msg.Event != SessionRevokedEvent || json.Unmarshal(msg.Data, &revocation) != nil {
+				continue
+			}
+			if revocation.SessionID == "" || revocation.SessionID == sessionID {
+				close(done)
+				unsubscribe()
+				return
+			}
+		}
+	}()
+	return done, unsubscribe
+}
@@ -0,0 +1,35 @@
+package realtime
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Chamanthra/TaskManager/events"
+)
+
+// TaskTopic is the topic carrying events for a single task
+func TaskTopic(taskID uint) string {
+	return fmt.Sprintf("task:%d", taskID)
+}
+
+// UserTasksTopic is the topic carrying events for every task a user owns
+func UserTasksTopic(userID uint) string {
+	return fmt.Sprintf("user-tasks:%d", userID)
+}
+
+// HandleEvent publishes a task, comment or file event to the task's topic and
+// to its owner's task list topic
+func HandleEvent(event events.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("realtime: failed to encode %s event: %w", event.Type, err)
+	}
+
+	for _, topic := range []string{TaskTopic(event.TaskID), UserTasksTopic(event.OwnerID)} {
+		msg := Message{Topic: topic, Event: event.Type, Data: data}
+		if err := DefaultBroker.Publish(msg); err != nil {
+			return fmt.Errorf("realtime: failed to publish %s event: %w", event.Type, err)
+		}
+	}
+	return nil
+}
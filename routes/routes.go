@@ -3,6 +3,7 @@ package routes
 import (
 	"github.com/Chamanthra/TaskManager/controllers"
 	"github.com/Chamanthra/TaskManager/middlewares"
+	"github.com/Chamanthra/TaskManager/models"
 	"github.com/gin-gonic/gin"
 )
 
@@ -19,6 +20,28 @@ func SetupRouter() *gin.Engine {
 	// User authentication routes
 	r.POST("/api/register", controllers.Register)
 	r.POST("/api/login", controllers.Login)
+	r.POST("/api/refresh", controllers.RefreshToken)
+
+	// Resumable upload discovery; the other tus routes require authentication
+	r.OPTIONS("/api/uploads", controllers.TusOptions)
+
+	// Downloads through signed links, which carry their own authorization
+	r.GET("/api/files/:fileId/download", controllers.DownloadSignedFile)
+
+	// Live task board updates over WebSocket
+	r.GET("/api/ws", middlewares.QueryTokenAuth(), middlewares.AuthMiddleware(), controllers.LiveUpdates)
+
+	// Permissions granted by the caller's role
+	var (
+		manageTasks    = middlewares.RequirePermission(models.PermissionManageTasks)
+		manageComments = middlewares.RequirePermission(models.PermissionManageComments)
+		manageFiles    = middlewares.RequirePermission(models.PermissionManageFiles)
+		manageUsers    = middlewares.RequirePermission(models.PermissionManageUsers)
+		allTasks       = middlewares.RequirePermission(models.PermissionAllTasks)
+		manageJobs     = middlewares.RequirePermission(models.PermissionManageJobs)
+		manageStorage  = middlewares.RequirePermission(models.PermissionManageStorage)
+		manageRoles    = middlewares.RequireAdmin()
+	)
 
 	protected := r.Group("/api")
 	protected.Use(middlewares.AuthMiddleware())
@@ -26,36 +49,90 @@ func SetupRouter() *gin.Engine {
 		// Task routes
 		taskRoutes := protected.Group("/tasks")
 		{
-			taskRoutes.POST("/", controllers.CreateTask)
+			taskRoutes.POST("/", manageTasks, controllers.CreateTask)
 			taskRoutes.GET("/", controllers.GetTasks)
-			taskRoutes.PUT("/:id", controllers.UpdateTask)
-			taskRoutes.DELETE("/:id", controllers.DeleteTask)
+			taskRoutes.PUT("/:taskId", manageTasks, controllers.UpdateTask)
+			taskRoutes.DELETE("/:taskId", manageTasks, controllers.DeleteTask)
 
 			// Task comments
-			taskRoutes.POST("/:taskId/comments", controllers.AddComment)
+			taskRoutes.POST("/:taskId/comments", manageComments, controllers.AddComment)
 			taskRoutes.GET("/:taskId/comments", controllers.GetTaskComments)
-			taskRoutes.DELETE("/comments/:commentId", controllers.DeleteComment)
+			taskRoutes.DELETE("/comments/:commentId", manageComments, controllers.DeleteComment)
 
 			// Task files
-			taskRoutes.POST("/:taskId/files", controllers.UploadFile)
+			taskRoutes.POST("/:taskId/files", manageFiles, controllers.UploadFile)
+			taskRoutes.GET("/:taskId/files", controllers.GetTaskFiles)
+			taskRoutes.GET("/:taskId/files/archive", controllers.DownloadTaskFilesArchive)
 			taskRoutes.GET("/files/:fileId", controllers.DownloadFile)
-			taskRoutes.DELETE("/files/:fileId", controllers.DeleteFile)
+			taskRoutes.DELETE("/files/:fileId", manageFiles, controllers.DeleteFile)
+			taskRoutes.POST("/files/:fileId/link", controllers.CreateDownloadLink)
+			taskRoutes.GET("/files/:fileId/preview", controllers.GetFilePreview)
+			taskRoutes.GET("/files/:fileId/thumbnail", controllers.GetFileThumbnail)
+			taskRoutes.POST("/files/:fileId/versions", manageFiles, controllers.UploadFileVersion)
+			taskRoutes.GET("/files/:fileId/versions", controllers.GetFileVersions)
+			taskRoutes.POST("/files/:fileId/versions/:version/restore", manageFiles, controllers.RestoreFileVersion)
+
+			// Per-task notification muting
+			taskRoutes.POST("/:taskId/mute", controllers.MuteTask)
+			taskRoutes.DELETE("/:taskId/mute", controllers.UnmuteTask)
 		}
 
+		// Resumable uploads (tus protocol)
+		protected.POST("/uploads", manageFiles, controllers.CreateUpload)
+		protected.HEAD("/uploads/:id", manageFiles, controllers.GetUploadOffset)
+		protected.PATCH("/uploads/:id", manageFiles, controllers.PatchUpload)
+		protected.DELETE("/uploads/:id", manageFiles, controllers.DeleteUpload)
+
 		// Notification routes
 		protected.GET("/notifications", controllers.GetUserNotifications)
+		protected.GET("/notifications/stream", controllers.StreamNotifications)
+		protected.GET("/notifications/unread-count", controllers.GetUnreadNotificationCount)
+		protected.PUT("/notifications/read-all", controllers.MarkAllNotificationsAsRead)
+		protected.DELETE("/notifications", controllers.DeleteNotifications)
+		protected.GET("/notifications/preferences", controllers.GetNotificationPreferences)
+		protected.PUT("/notifications/preferences", controllers.UpdateNotificationPreferences)
 		protected.PUT("/notifications/:id/read", controllers.MarkNotificationAsRead)
 
+		// Webhook subscriptions
+		webhookRoutes := protected.Group("/webhooks")
+		{
+			webhookRoutes.POST("/", controllers.CreateWebhook)
+			webhookRoutes.GET("/", controllers.GetWebhooks)
+			webhookRoutes.PUT("/:id", controllers.UpdateWebhook)
+			webhookRoutes.DELETE("/:id", controllers.DeleteWebhook)
+			webhookRoutes.GET("/:id/deliveries", controllers.GetWebhookDeliveries)
+			webhookRoutes.POST("/:id/deliveries/:deliveryId/redeliver", controllers.RedeliverWebhook)
+		}
+
 		// User profile routes
 		protected.GET("/profile", controllers.GetUserProfile)
 		protected.PUT("/profile", controllers.UpdateUserProfile)
+		protected.POST("/logout", controllers.Logout)
+		protected.POST("/logout-all", controllers.LogoutAll)
 
-		// Admin-only routes
+		// Administration routes, each requiring its own permission
 		adminRoutes := protected.Group("/admin")
 		{
-			adminRoutes.GET("/users", controllers.GetUsers)
-			adminRoutes.DELETE("/users/:id", controllers.DeleteUser)
-			adminRoutes.GET("/tasks/all", controllers.GetAllTasks)
+			adminRoutes.GET("/users", manageUsers, controllers.GetUsers)
+			adminRoutes.DELETE("/users/:id", manageUsers, controllers.DeleteUser)
+			adminRoutes.GET("/quotas", manageUsers, controllers.GetStorageQuotas)
+			adminRoutes.PUT("/users/:id/quota", manageUsers, controllers.UpdateStorageQuota)
+			adminRoutes.GET("/tasks/all", allTasks, controllers.GetAllTasks)
+			adminRoutes.GET("/jobs", manageJobs, controllers.GetScheduledJobs)
+			adminRoutes.POST("/jobs/:name/run", manageJobs, controllers.RunScheduledJob)
+			adminRoutes.GET("/queue", manageJobs, controllers.GetQueuedJobs)
+			adminRoutes.POST("/queue/:id/retry", manageJobs, controllers.RetryQueuedJob)
+			adminRoutes.POST("/storage/check", manageStorage, controllers.CheckStorage)
+
+			// Roles and permissions
+			adminRoutes.GET("/roles", manageRoles, controllers.GetRoles)
+			adminRoutes.POST("/roles", manageRoles, controllers.CreateRole)
+			adminRoutes.PUT("/roles/:id", manageRoles, controllers.UpdateRole)
+			adminRoutes.DELETE("/roles/:id", manageRoles, controllers.DeleteRole)
+			adminRoutes.PUT("/users/:id/role", manageRoles, controllers.UpdateUserRole)
+			adminRoutes.GET("/permissions", manageRoles, controllers.GetPermissions)
+			adminRoutes.POST("/permissions", manageRoles, controllers.CreatePermission)
+			adminRoutes.DELETE("/permissions/:id", manageRoles, controllers.DeletePermission)
 		}
 	}
 
@@ -0,0 +1,128 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// chunkSize is the size of the chunks streamed to clamd. clamd's
+// StreamMaxLength still limits the total size of a scanned file; larger files
+// fail with ErrTooLarge.
+const chunkSize = 64 << 10
+
+// Clamd scans files with a ClamAV daemon using the INSTREAM command
+type Clamd struct {
+	network string
+	address string
+}
+
+// NewClamd parses an address of the form unix:///path/to/clamd.sock or
+// tcp://host:3310
+func NewClamd(address string) (*Clamd, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("clamd: invalid address %q: %w", address, err)
+	}
+	switch u.Scheme {
+	case "unix":
+		return &Clamd{network: "unix", address: u.Path}, nil
+	case "tcp":
+		return &Clamd{network: "tcp", address: u.Host}, nil
+	default:
+		return nil, fmt.Errorf("clamd: unsupported address scheme %q", u.Scheme)
+	}
+}
+
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return Result{}, fmt.Errorf("clamd: %w", err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(5 * time.Minute))
+	}
+
+	if _, err := conn.Write([]byte("zINSTREAM\x00")); err != nil {
+		return Result{}, fmt.Errorf("clamd: %w", err)
+	}
+
+	// Each chunk is prefixed with its length; a zero length ends the stream.
+	// clamd replies and closes the connection early if the stream exceeds
+	// StreamMaxLength, so a failed write is followed by reading that reply.
+	if err := stream(conn, r); err != nil {
+		if result, replyErr := readReply(conn); errors.Is(replyErr, ErrTooLarge) {
+			return result, replyErr
+		}
+		return Result{}, err
+	}
+	return readReply(conn)
+}
+
+// stream sends the contents in length-prefixed chunks, ending with a zero
+// length chunk
+func stream(conn net.Conn, r io.Reader) error {
+	buf := make([]byte, chunkSize)
+	size := make([]byte, 4)
+	for {
+		n, readErr := r.Read(buf)
+		if n > 0 {
+			binary.BigEndian.PutUint32(size, uint32(n))
+			if _, err := conn.Write(size); err != nil {
+				return fmt.Errorf("clamd: %w", err)
+			}
+			if _, err := conn.Write(buf[:n]); err != nil {
+				return fmt.Errorf("clamd: %w", err)
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return readErr
+		}
+	}
+	binary.BigEndian.PutUint32(size, 0)
+	if _, err := conn.Write(size); err != nil {
+		return fmt.Errorf("clamd: %w", err)
+	}
+	return nil
+}
+
+func readReply(conn net.Conn) (Result, error) {
+	reply, err := bufio.NewReader(conn).ReadBytes(0)
+	if err != nil && err != io.EOF {
+		return Result{}, fmt.Errorf("clamd: %w", err)
+	}
+	return parseReply(string(bytes.TrimRight(reply, "\x00\n")))
+}
+
+// parseReply interprets replies such as "stream: OK",
+// "stream: Eicar-Test-Signature FOUND" and
+// "INSTREAM size limit exceeded. ERROR"
+func parseReply(reply string) (Result, error) {
+	status := strings.TrimSpace(strings.TrimPrefix(reply, "stream:"))
+	switch {
+	case status == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(status, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(status, " FOUND")}, nil
+	case strings.Contains(status, "size limit exceeded"):
+		return Result{}, fmt.Errorf("clamd: %s: %w", reply, ErrTooLarge)
+	default:
+		return Result{}, fmt.Errorf("clamd: %s", reply)
+	}
+}
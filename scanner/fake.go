@@ -0,0 +1,26 @@
+package scanner
+
+import (
+	"bytes"
+	"context"
+	"io"
+)
+
+// eicar is the standard antivirus test file
+// (https://www.eicar.org/download-anti-malware-testfile/)
+var eicar = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+// Fake reports files containing the EICAR test string as infected and
+// everything else as clean
+type Fake struct{}
+
+func (Fake) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return Result{}, err
+	}
+	if bytes.Contains(data, eicar) {
+		return Result{Infected: true, Signature: "Eicar-Test-Signature"}, nil
+	}
+	return Result{}, nil
+}
@@ -0,0 +1,47 @@
+package scanner
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+)
+
+// ErrTooLarge is returned for files larger than the scanner accepts, such as
+// files over clamd's StreamMaxLength. Scanning them again will not help.
+var ErrTooLarge = errors.New("file exceeds the scanner's size limit")
+
+// Result is the verdict of a scan
+type Result struct {
+	Infected  bool
+	Signature string // Name of the detected malware
+}
+
+// Scanner checks file contents for malware
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
+
+// Default is the scanner used for uploaded files
+var Default Scanner
+
+// Setup selects the scanner from the SCANNER environment variable: "clamd"
+// (the default) connects to ClamAV at CLAMD_ADDRESS, "fake" only detects the
+// EICAR test file and is meant for development and tests
+func Setup() error {
+	switch os.Getenv("SCANNER") {
+	case "fake":
+		Default = Fake{}
+	default:
+		address := os.Getenv("CLAMD_ADDRESS")
+		if address == "" {
+			address = "unix:///var/run/clamav/clamd.ctl"
+		}
+		clamd, err := NewClamd(address)
+		if err != nil {
+			return err
+		}
+		Default = clamd
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestFakeDetectsEicar(t *testing.T) {
+	infected := append([]byte("prefix "), eicar...)
+	result, err := Fake{}.Scan(context.Background(), bytes.NewReader(infected))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("Scan(eicar) = %+v, %v", result, err)
+	}
+
+	result, err = Fake{}.Scan(context.Background(), strings.NewReader("hello"))
+	if err != nil || result.Infected {
+		t.Fatalf("Scan(clean) = %+v, %v", result, err)
+	}
+}
+
+func TestParseReply(t *testing.T) {
+	result, err := parseReply("stream: OK")
+	if err != nil || result.Infected {
+		t.Errorf("OK reply = %+v, %v", result, err)
+	}
+
+	result, err = parseReply("stream: Eicar-Test-Signature FOUND")
+	if err != nil || !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Errorf("FOUND reply = %+v, %v", result, err)
+	}
+
+	_, err = parseReply("INSTREAM size limit exceeded. ERROR")
+	if !errors.Is(err, ErrTooLarge) {
+		t.Errorf("size limit reply: err = %v, want ErrTooLarge", err)
+	}
+
+	_, err = parseReply("stream: Can't allocate memory ERROR")
+	if err == nil || errors.Is(err, ErrTooLarge) {
+		t.Errorf("ERROR reply: err = %v, want a scan error", err)
+	}
+}
+
+// fakeClamd accepts one INSTREAM connection, reads the chunks and replies
+// with reply(contents)
+func fakeClamd(t *testing.T, reply func(contents []byte) string) *Clamd {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		if command, err := r.ReadString(0); err != nil || command != "zINSTREAM\x00" {
+			return
+		}
+		var contents []byte
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			contents = append(contents, chunk...)
+		}
+		conn.Write([]byte(reply(contents) + "\x00"))
+	}()
+
+	clamd, err := NewClamd("tcp://" + listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return clamd
+}
+
+func TestClamdScan(t *testing.T) {
+	clamd := fakeClamd(t, func(contents []byte) string {
+		if bytes.Contains(contents, eicar) {
+			return "stream: Eicar-Test-Signature FOUND"
+		}
+		return "stream: OK"
+	})
+	// Larger than one chunk, to exercise the chunking
+	contents := append(bytes.Repeat([]byte("a"), chunkSize+10), eicar...)
+	result, err := clamd.Scan(context.Background(), bytes.NewReader(contents))
+	if err != nil || !result.Infected || result.Signature != "Eicar-Test-Signature" {
+		t.Fatalf("Scan = %+v, %v", result, err)
+	}
+}
+
+func TestClamdScanTooLarge(t *testing.T) {
+	clamd := fakeClamd(t, func([]byte) string { return "INSTREAM size limit exceeded. ERROR" })
+	_, err := clamd.Scan(context.Background(), strings.NewReader("contents"))
+	if !errors.Is(err, ErrTooLarge) {
+		t.Fatalf("Scan: err = %v, want ErrTooLarge", err)
+	}
+}
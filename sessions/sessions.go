@@ -0,0 +1,211 @@
+package sessions
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/realtime"
+	"github.com/Chamanthra/TaskManager/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// RefreshTokenTTL is how long a session lasts without being refreshed
+const RefreshTokenTTL = 30 * 24 * time.Hour
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	// ErrRefreshTokenReused means a refresh token was presented after it had
+	// been exchanged, so it has probably been stolen. The whole session is
+	// revoked.
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
+
+// Tokens is an access token with the refresh token that replaces it
+type Tokens struct {
+	AccessToken  string    `json:"token"`
+	ExpiresAt    time.Time `json:"expires_at"`
+	RefreshToken string    `json:"refresh_token"`
+}
+
+// Start begins a new session for a user who has logged in
+func Start(db *gorm.DB, user *models.User) (*Tokens, error) {
+	sessionID, err := utils.RandomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return issue(db, user, sessionID)
+}
+
+// Refresh exchanges a refresh token for new tokens of the same session. The
+// refresh token can only be used once.
+func Refresh(db *gorm.DB, refreshToken string) (*Tokens, error) {
+	var (
+		tokens *Tokens
+		reused *models.RefreshToken
+	)
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var stored models.RefreshToken
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("token_hash = ?", hashToken(refreshToken)).
+			Take(&stored).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrInvalidRefreshToken
+		}
+		if err != nil {
+			return err
+		}
+
+		// Returning nil commits the revocation of the session
+		if stored.UsedAt != nil {
+			reused = &stored
+			log.Printf("sessions: refresh token of user %d reused, revoking session %s", stored.UserID, stored.FamilyID)
+			return revokeFamily(tx, stored.FamilyID)
+		}
+		if stored.RevokedAt != nil || stored.ExpiresAt.Before(time.Now()) {
+			return ErrInvalidRefreshToken
+		}
+
+		if err := tx.Model(&stored).Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		var user models.User
+		if err := tx.Preload("Role").First(&user, stored.UserID).Error; err != nil {
+			return ErrInvalidRefreshToken
+		}
+		if !user.IsActive {
+			return ErrInvalidRefreshToken
+		}
+		tokens, err = issue(tx, &user, stored.FamilyID)
+		return err
+	})
+	if err == nil && reused != nil {
+		realtime.PublishSessionRevoked(reused.UserID, reused.FamilyID)
+		return nil, ErrRefreshTokenReused
+	}
+	return tokens, err
+}
+
+// Logout revokes an access token and the refresh token of its session, and
+// closes live connections the session opened
+func Logout(db *gorm.DB, userID uint, jti string, expiresAt time.Time, sessionID string) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := revokeAccessToken(tx, jti, expiresAt); err != nil {
+			return err
+		}
+		return revokeFamily(tx, sessionID)
+	})
+	if err != nil {
+		return err
+	}
+	realtime.PublishSessionRevoked(userID, sessionID)
+	return nil
+}
+
+// LogoutAll ends every session of a user. Incrementing the token version
+// invalidates all of their access tokens without listing them.
+func LogoutAll(db *gorm.DB, userID uint) error {
+	err := db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.User{}).Where("id = ?", userID).
+			Update("token_version", gorm.Expr("token_version + 1")).Error
+		if err != nil {
+			return err
+		}
+		return RevokeRefreshTokens(tx, userID)
+	})
+	if err != nil {
+		return err
+	}
+	realtime.PublishSessionRevoked(userID, "")
+	return nil
+}
+
+// RevokeRefreshTokens revokes every refresh token of a user, so their
+// sessions cannot be renewed. Callers changing the token version in the same
+// transaction publish the revocation once it commits.
+func RevokeRefreshTokens(tx *gorm.DB, userID uint) error {
+	return tx.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", userID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// IsRevoked reports whether an access token has been revoked
+func IsRevoked(db *gorm.DB, jti string) (bool, error) {
+	var count int64
+	err := db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// PruneExpired deletes refresh tokens and revocations that have expired
+func PruneExpired(ctx context.Context) error {
+	db := config.DB.WithContext(ctx)
+	now := time.Now()
+
+	revoked := db.Where("expires_at < ?", now).Delete(&models.RevokedToken{})
+	if revoked.Error != nil {
+		return fmt.Errorf("failed to prune revoked tokens: %w", revoked.Error)
+	}
+	refresh := db.Where("expires_at < ?", now).Delete(&models.RefreshToken{})
+	if refresh.Error != nil {
+		return fmt.Errorf("failed to prune refresh tokens: %w", refresh.Error)
+	}
+	if revoked.RowsAffected > 0 || refresh.RowsAffected > 0 {
+		log.Printf("sessions: pruned %d revoked access tokens and %d refresh tokens", revoked.RowsAffected, refresh.RowsAffected)
+	}
+	return nil
+}
+
+// issue creates an access token and a new refresh token for a session
+func issue(tx *gorm.DB, user *models.User, sessionID string) (*Tokens, error) {
+	accessToken, expiresAt, err := utils.GenerateJWT(user.ID, user.Role.Name, user.TokenVersion, sessionID)
+	if err != nil {
+		return nil, err
+	}
+	refreshToken, err := utils.RandomToken(32)
+	if err != nil {
+		return nil, err
+	}
+
+	stored := models.RefreshToken{
+		UserID:    user.ID,
+		FamilyID:  sessionID,
+		TokenHash: hashToken(refreshToken),
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+	}
+	if err := tx.Create(&stored).Error; err != nil {
+		return nil, err
+	}
+	return &Tokens{AccessToken: accessToken, ExpiresAt: expiresAt, RefreshToken: refreshToken}, nil
+}
+
+func revokeAccessToken(tx *gorm.DB, jti string, expiresAt time.Time) error {
+	if jti == "" {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+}
+
+func revokeFamily(tx *gorm.DB, sessionID string) error {
+	if sessionID == "" {
+		return nil
+	}
+	return tx.Model(&models.RefreshToken{}).
+		Where("family_id = ? AND revoked_at IS NULL", sessionID).
+		Update("revoked_at", time.Now()).Error
+}
+
+// hashToken is how refresh tokens are stored, so a database leak does not
+// expose usable tokens
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,178 @@
+package sessions
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/realtime"
+	"github.com/Chamanthra/TaskManager/utils"
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testUser connects to the Postgres database named by TEST_DATABASE_DSN,
+// such as the one in docker-compose.yml, and creates a user inside a
+// transaction that is rolled back when the test ends. Tests using it are
+// skipped when the variable is not set.
+func testUser(t *testing.T) (*gorm.DB, *models.User) {
+	dsn := os.Getenv("TEST_DATABASE_DSN")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_DSN is not set")
+	}
+	t.Setenv("JWT_SECRET", "test-secret")
+
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to connect: %v", err)
+	}
+	err = db.AutoMigrate(&models.Permission{}, &models.Role{}, &models.User{}, &models.RefreshToken{}, &models.RevokedToken{})
+	if err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+
+	tx := db.Begin()
+	t.Cleanup(func() { tx.Rollback() })
+
+	suffix, err := utils.RandomToken(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	role := models.Role{Role: "sessions-test-" + suffix, Name: "Sessions test"}
+	if err := tx.Create(&role).Error; err != nil {
+		t.Fatalf("failed to create role: %v", err)
+	}
+	user := models.User{
+		UserName: "sessions-" + suffix,
+		Email:    "sessions-" + suffix + "@example.com",
+		Password: "x",
+		RoleID:   role.ID,
+		IsActive: true,
+		Role:     role,
+	}
+	if err := tx.Omit("Role").Create(&user).Error; err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	return tx, &user
+}
+
+func parseClaims(t *testing.T, accessToken string) jwt.MapClaims {
+	t.Helper()
+	token, err := jwt.Parse(accessToken, func(*jwt.Token) (interface{}, error) {
+		return []byte("test-secret"), nil
+	})
+	if err != nil {
+		t.Fatalf("failed to parse access token: %v", err)
+	}
+	return token.Claims.(jwt.MapClaims)
+}
+
+func TestRefreshRotatesAndDetectsReuse(t *testing.T) {
+	db, user := testUser(t)
+
+	first, err := Start(db, user)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	sessionID, _ := parseClaims(t, first.AccessToken)["sid"].(string)
+	revoked, stop := realtime.WatchSession(user.ID, sessionID)
+	defer stop()
+
+	second, err := Refresh(db, first.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatal("Refresh did not rotate the tokens")
+	}
+	if sid := parseClaims(t, second.AccessToken)["sid"]; sid != sessionID {
+		t.Fatalf("refreshed token belongs to session %v, want %s", sid, sessionID)
+	}
+
+	// Presenting the exchanged token again revokes the whole session
+	if _, err := Refresh(db, first.RefreshToken); !errors.Is(err, ErrRefreshTokenReused) {
+		t.Fatalf("reusing a refresh token: err = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := Refresh(db, second.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("refreshing a revoked session: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	select {
+	case <-revoked:
+	case <-time.After(time.Second):
+		t.Fatal("live connections of the session were not told it was revoked")
+	}
+
+	// Other sessions of the user are unaffected
+	other, err := Start(db, user)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	if _, err := Refresh(db, other.RefreshToken); err != nil {
+		t.Fatalf("refreshing another session: %v", err)
+	}
+}
+
+func TestLogoutRevokesAccessToken(t *testing.T) {
+	db, user := testUser(t)
+
+	tokens, err := Start(db, user)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	claims := parseClaims(t, tokens.AccessToken)
+	jti, _ := claims["jti"].(string)
+	sessionID, _ := claims["sid"].(string)
+
+	if err := Logout(db, user.ID, jti, tokens.ExpiresAt, sessionID); err != nil {
+		t.Fatalf("Logout: %v", err)
+	}
+	if revoked, err := IsRevoked(db, jti); err != nil || !revoked {
+		t.Fatalf("IsRevoked after Logout = %v, %v", revoked, err)
+	}
+	if _, err := Refresh(db, tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("refreshing after Logout: err = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestLogoutAllInvalidatesEarlierTokens(t *testing.T) {
+	db, user := testUser(t)
+
+	first, err := Start(db, user)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	second, err := Start(db, user)
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	revoked, stop := realtime.WatchSession(user.ID, "")
+	defer stop()
+
+	if err := LogoutAll(db, user.ID); err != nil {
+		t.Fatalf("LogoutAll: %v", err)
+	}
+
+	// Access tokens are only accepted while their version matches the user's
+	var current models.User
+	if err := db.First(&current, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, tokens := range []*Tokens{first, second} {
+		version, _ := parseClaims(t, tokens.AccessToken)["token_version"].(float64)
+		if int(version) == current.TokenVersion {
+			t.Fatalf("access token version %d still matches the user's", int(version))
+		}
+		if _, err := Refresh(db, tokens.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+			t.Fatalf("refreshing after LogoutAll: err = %v, want ErrInvalidRefreshToken", err)
+		}
+	}
+	select {
+	case <-revoked:
+	case <-time.After(time.Second):
+		t.Fatal("live connections were not told every session was revoked")
+	}
+}
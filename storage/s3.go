@@ -0,0 +1,437 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	s3Algorithm      = "AWS4-HMAC-SHA256"
+	s3UnsignedBody   = "UNSIGNED-PAYLOAD"
+	s3TimeFormat     = "20060102T150405Z"
+	s3DateFormat     = "20060102"
+	s3MaxPresignTime = 7 * 24 * time.Hour
+)
+
+// S3Config holds the connection settings for an S3-compatible service
+type S3Config struct {
+	Endpoint  string // e.g. https://s3.eu-west-1.amazonaws.com or http://localhost:9000
+	Bucket    string
+	Region    string // Defaults to us-east-1
+	AccessKey string
+	SecretKey string
+}
+
+// S3Storage stores objects in a bucket of an S3-compatible service such as
+// AWS S3 or MinIO. It uses path-style addressing and signs requests with
+// AWS Signature Version 4.
+type S3Storage struct {
+	cfg      S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// NewS3Storage validates the configuration and creates the bucket if it does
+// not exist yet
+func NewS3Storage(cfg S3Config) (*S3Storage, error) {
+	if cfg.Endpoint == "" || cfg.Bucket == "" || cfg.AccessKey == "" || cfg.SecretKey == "" {
+		return nil, errors.New("s3: S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY and S3_SECRET_KEY must be set")
+	}
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+	endpoint, err := url.Parse(strings.TrimRight(cfg.Endpoint, "/"))
+	if err != nil || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3: invalid endpoint %q", cfg.Endpoint)
+	}
+
+	s := &S3Storage{cfg: cfg, endpoint: endpoint, client: &http.Client{}}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := s.ensureBucket(ctx); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *S3Storage) ensureBucket(ctx context.Context) error {
+	resp, err := s.do(ctx, http.MethodHead, "", nil, -1, nil)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	if resp.StatusCode != http.StatusNotFound {
+		return fmt.Errorf("s3: bucket %s: %s", s.cfg.Bucket, resp.Status)
+	}
+
+	var body []byte
+	if s.cfg.Region != "us-east-1" {
+		body = []byte("<CreateBucketConfiguration><LocationConstraint>" + s.cfg.Region + "</LocationConstraint></CreateBucketConfiguration>")
+	}
+	resp, err = s.do(ctx, http.MethodPut, "", bytes.NewReader(body), int64(len(body)), nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError("create bucket", resp)
+	}
+	return nil
+}
+
+func (s *S3Storage) Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+	if size < 0 {
+		return errors.New("s3: object size must be known in advance")
+	}
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+
+	resp, err := s.do(ctx, http.MethodPut, key, r, size, header)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return responseError("put "+key, resp)
+	}
+	return nil
+}
+
+func (s *S3Storage) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+	resp, err := s.do(ctx, http.MethodGet, key, nil, -1, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		if resp.StatusCode == http.StatusNotFound {
+			return nil, nil, ErrNotFound
+		}
+		return nil, nil, responseError("get "+key, resp)
+	}
+	return resp.Body, objectFromResponse(key, resp), nil
+}
+
+func (s *S3Storage) GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error) {
+	header := http.Header{}
+	if length < 0 {
+		header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	} else if length == 0 {
+		return io.NopCloser(strings.NewReader("")), nil
+	} else {
+		header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+	}
+
+	resp, err := s.do(ctx, http.MethodGet, key, nil, -1, header)
+	if err != nil {
+		return nil, err
+	}
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		return resp.Body, nil
+	case http.StatusOK:
+		// The service ignored the range; skip to it ourselves
+		if _, err := io.CopyN(io.Discard, resp.Body, offset); err != nil {
+			resp.Body.Close()
+			return nil, err
+		}
+		if length < 0 {
+			return resp.Body, nil
+		}
+		return limitedReadCloser{io.LimitReader(resp.Body, length), resp.Body}, nil
+	case http.StatusNotFound:
+		resp.Body.Close()
+		return nil, ErrNotFound
+	default:
+		defer resp.Body.Close()
+		return nil, responseError("get "+key, resp)
+	}
+}
+
+func (s *S3Storage) Delete(ctx context.Context, key string) error {
+	resp, err := s.do(ctx, http.MethodDelete, key, nil, -1, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusNoContent && resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNotFound {
+		return responseError("delete "+key, resp)
+	}
+	return nil
+}
+
+func (s *S3Storage) Stat(ctx context.Context, key string) (*Object, error) {
+	resp, err := s.do(ctx, http.MethodHead, key, nil, -1, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return objectFromResponse(key, resp), nil
+	case http.StatusNotFound:
+		return nil, ErrNotFound
+	default:
+		return nil, fmt.Errorf("s3: stat %s: %s", key, resp.Status)
+	}
+}
+
+// List pages through the bucket with ListObjectsV2
+func (s *S3Storage) List(ctx context.Context, prefix string, fn func(Object) error) error {
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u := s.objectURL("")
+		u.RawQuery = canonicalQuery(query)
+
+		resp, err := s.send(ctx, http.MethodGet, u, nil, -1, nil)
+		if err != nil {
+			return fmt.Errorf("s3: list %s: %w", prefix, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			err := responseError("list "+prefix, resp)
+			resp.Body.Close()
+			return err
+		}
+
+		var page struct {
+			Contents []struct {
+				Key          string    `xml:"Key"`
+				Size         int64     `xml:"Size"`
+				LastModified time.Time `xml:"LastModified"`
+			} `xml:"Contents"`
+			IsTruncated           bool   `xml:"IsTruncated"`
+			NextContinuationToken string `xml:"NextContinuationToken"`
+		}
+		err = xml.NewDecoder(resp.Body).Decode(&page)
+		resp.Body.Close()
+		if err != nil {
+			return fmt.Errorf("s3: list %s: %w", prefix, err)
+		}
+
+		for _, item := range page.Contents {
+			if err := fn(Object{Key: item.Key, Size: item.Size, ModTime: item.LastModified}); err != nil {
+				return err
+			}
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			return nil
+		}
+		token = page.NextContinuationToken
+	}
+}
+
+// Presign builds a query-string signed GET URL. S3 caps the expiry at seven
+// days.
+func (s *S3Storage) Presign(ctx context.Context, key string, expiry time.Duration, filename string) (string, error) {
+	if expiry <= 0 || expiry > s3MaxPresignTime {
+		return "", fmt.Errorf("s3: presign expiry must be between 1s and %s", s3MaxPresignTime)
+	}
+
+	now := time.Now().UTC()
+	u := s.objectURL(key)
+	query := url.Values{}
+	query.Set("X-Amz-Algorithm", s3Algorithm)
+	query.Set("X-Amz-Credential", s.cfg.AccessKey+"/"+s.scope(now))
+	query.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	query.Set("X-Amz-Expires", strconv.Itoa(int(expiry.Seconds())))
+	query.Set("X-Amz-SignedHeaders", "host")
+	if filename != "" {
+		query.Set("response-content-disposition", mime.FormatMediaType("attachment", map[string]string{"filename": filename}))
+	}
+	u.RawQuery = canonicalQuery(query)
+
+	header := http.Header{}
+	header.Set("Host", u.Host)
+	signature := s.signature(now, http.MethodGet, u, header, s3UnsignedBody)
+	u.RawQuery += "&X-Amz-Signature=" + signature
+	return u.String(), nil
+}
+
+// do sends a signed request for key, or for the bucket itself if key is empty
+func (s *S3Storage) do(ctx context.Context, method, key string, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	resp, err := s.send(ctx, method, s.objectURL(key), body, size, header)
+	if err != nil {
+		return nil, fmt.Errorf("s3: %s %s: %w", method, key, err)
+	}
+	return resp, nil
+}
+
+// send signs and sends a request. Any query in u must be in canonical form.
+func (s *S3Storage) send(ctx context.Context, method string, u *url.URL, body io.Reader, size int64, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), body)
+	if err != nil {
+		return nil, err
+	}
+	for name, values := range header {
+		req.Header[name] = values
+	}
+	if size >= 0 {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+
+	now := time.Now().UTC()
+	req.Header.Set("Host", u.Host)
+	req.Header.Set("X-Amz-Date", now.Format(s3TimeFormat))
+	req.Header.Set("X-Amz-Content-Sha256", s3UnsignedBody)
+	req.Header.Set("Authorization", fmt.Sprintf("%s Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s3Algorithm, s.cfg.AccessKey, s.scope(now), signedHeaders(req.Header),
+		s.signature(now, method, u, req.Header, s3UnsignedBody)))
+
+	return s.client.Do(req)
+}
+
+// objectURL returns the path-style URL of key in the bucket
+func (s *S3Storage) objectURL(key string) *url.URL {
+	u := *s.endpoint
+	u.Path = s.endpoint.Path + "/" + s.cfg.Bucket
+	u.RawPath = escapePath(s.endpoint.Path) + "/" + escapePath(s.cfg.Bucket)
+	if key != "" {
+		u.Path += "/" + key
+		u.RawPath += "/" + escapePath(key)
+	}
+	return &u
+}
+
+func (s *S3Storage) scope(t time.Time) string {
+	return t.Format(s3DateFormat) + "/" + s.cfg.Region + "/s3/aws4_request"
+}
+
+// signature computes the Signature Version 4 signature of a request. Only the
+// headers listed by signedHeaders are included.
+func (s *S3Storage) signature(t time.Time, method string, u *url.URL, header http.Header, payloadHash string) string {
+	names := strings.Split(signedHeaders(header), ";")
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + strings.TrimSpace(header.Get(name)) + "\n")
+	}
+
+	canonicalRequest := strings.Join([]string{
+		method,
+		u.EscapedPath(),
+		u.RawQuery,
+		canonicalHeaders.String(),
+		strings.Join(names, ";"),
+		payloadHash,
+	}, "\n")
+
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := strings.Join([]string{
+		s3Algorithm,
+		t.Format(s3TimeFormat),
+		s.scope(t),
+		hex.EncodeToString(hash[:]),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+s.cfg.SecretKey), t.Format(s3DateFormat))
+	key = hmacSHA256(key, s.cfg.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	return hex.EncodeToString(hmacSHA256(key, stringToSign))
+}
+
+// signedHeaders lists the headers covered by the signature: Host, Content-Type
+// and the x-amz-* headers
+func signedHeaders(header http.Header) string {
+	var names []string
+	for name := range header {
+		lower := strings.ToLower(name)
+		if lower == "host" || lower == "content-type" || strings.HasPrefix(lower, "x-amz-") {
+			names = append(names, lower)
+		}
+	}
+	sort.Strings(names)
+	return strings.Join(names, ";")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// escape percent-encodes everything except the unreserved characters, as
+// Signature Version 4 requires
+func escape(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'A' <= c && c <= 'Z' || 'a' <= c && c <= 'z' || '0' <= c && c <= '9' || c == '-' || c == '_' || c == '.' || c == '~' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
+
+func escapePath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		segments[i] = escape(segment)
+	}
+	return strings.Join(segments, "/")
+}
+
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for key := range query {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	for _, key := range keys {
+		for _, value := range query[key] {
+			parts = append(parts, escape(key)+"="+escape(value))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func objectFromResponse(key string, resp *http.Response) *Object {
+	obj := &Object{Key: key, Size: resp.ContentLength, ContentType: resp.Header.Get("Content-Type")}
+	if modified, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		obj.ModTime = modified
+	}
+	return obj
+}
+
+// responseError extracts the error code from an S3 XML error response
+func responseError(op string, resp *http.Response) error {
+	var body struct {
+		Code    string `xml:"Code"`
+		Message string `xml:"Message"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	if xml.Unmarshal(data, &body) == nil && body.Code != "" {
+		return fmt.Errorf("s3: %s: %s: %s (%s)", op, resp.Status, body.Code, body.Message)
+	}
+	return fmt.Errorf("s3: %s: %s", op, resp.Status)
+}
@@ -0,0 +1,71 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+// Seeker reads an object through an io.ReadSeeker, as http.ServeContent
+// needs. Nothing is fetched until the first Read, and a Seek only reopens the
+// object at the new offset on the next Read, so serving a byte range
+// transfers just that range from the storage backend.
+type Seeker struct {
+	ctx    context.Context
+	store  Storage
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+// NewSeeker returns a Seeker over an object of the given size
+func NewSeeker(ctx context.Context, store Storage, key string, size int64) *Seeker {
+	return &Seeker{ctx: ctx, store: store, key: key, size: size}
+}
+
+func (s *Seeker) Read(p []byte) (int, error) {
+	if s.offset >= s.size {
+		return 0, io.EOF
+	}
+	if s.body == nil {
+		body, err := s.store.GetRange(s.ctx, s.key, s.offset, -1)
+		if err != nil {
+			return 0, err
+		}
+		s.body = body
+	}
+
+	n, err := s.body.Read(p)
+	s.offset += int64(n)
+	return n, err
+}
+
+func (s *Seeker) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += s.offset
+	case io.SeekEnd:
+		offset += s.size
+	default:
+		return 0, errors.New("storage: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("storage: negative position")
+	}
+
+	if offset != s.offset && s.body != nil {
+		s.body.Close()
+		s.body = nil
+	}
+	s.offset = offset
+	return offset, nil
+}
+
+func (s *Seeker) Close() error {
+	if s.body != nil {
+		return s.body.Close()
+	}
+	return nil
+}
@@ -0,0 +1,78 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"time"
+)
+
+var (
+	ErrNotFound     = errors.New("object not found")
+	ErrNotSupported = errors.New("operation not supported by storage driver")
+)
+
+// Object describes a stored file
+type Object struct {
+	Key         string
+	Size        int64
+	ContentType string
+	ModTime     time.Time
+}
+
+// Storage stores file contents under opaque keys. Keys use "/" as separator
+// regardless of the driver.
+type Storage interface {
+	// Put stores size bytes read from r under key, replacing any existing object
+	Put(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+	// Get opens the object for reading. The caller must close the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+	// GetRange opens length bytes of the object starting at offset. A negative
+	// length reads to the end.
+	GetRange(ctx context.Context, key string, offset, length int64) (io.ReadCloser, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// Stat returns the object's metadata without reading its contents
+	Stat(ctx context.Context, key string) (*Object, error)
+	// List calls fn for every object whose key starts with prefix, in no
+	// particular order, stopping at the first error fn returns
+	List(ctx context.Context, prefix string, fn func(Object) error) error
+	// Presign returns a URL from which the object can be downloaded without
+	// credentials until expiry, served as an attachment named filename.
+	// Drivers without their own HTTP endpoint return ErrNotSupported.
+	Presign(ctx context.Context, key string, expiry time.Duration, filename string) (string, error)
+}
+
+// Default is the storage used by the controllers and workers
+var Default Storage
+
+// Setup selects the storage driver from the STORAGE_DRIVER environment
+// variable ("local" or "s3")
+func Setup() error {
+	switch os.Getenv("STORAGE_DRIVER") {
+	case "s3":
+		s, err := NewS3Storage(S3Config{
+			Endpoint:  os.Getenv("S3_ENDPOINT"),
+			Bucket:    os.Getenv("S3_BUCKET"),
+			Region:    os.Getenv("S3_REGION"),
+			AccessKey: os.Getenv("S3_ACCESS_KEY"),
+			SecretKey: os.Getenv("S3_SECRET_KEY"),
+		})
+		if err != nil {
+			return err
+		}
+		Default = s
+	default:
+		dir := os.Getenv("STORAGE_LOCAL_DIR")
+		if dir == "" {
+			dir = "./uploads"
+		}
+		s, err := NewLocalStorage(dir)
+		if err != nil {
+			return err
+		}
+		Default = s
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyDownload(t *testing.T) {
+	t.Setenv("DOWNLOAD_LINK_SECRET", "download-secret")
+	t.Setenv("JWT_SECRET", "jwt-secret")
+
+	expires := time.Now().Add(time.Hour)
+	signature, err := SignDownload(42, expires)
+	if err != nil {
+		t.Fatalf("SignDownload: %v", err)
+	}
+	expired := time.Now().Add(-time.Second)
+	expiredSignature, err := SignDownload(42, expired)
+	if err != nil {
+		t.Fatalf("SignDownload: %v", err)
+	}
+
+	// Flip the last hex digit of the signature
+	last := signature[len(signature)-1:]
+	flipped := "0"
+	if last == "0" {
+		flipped = "1"
+	}
+	tampered := signature[:len(signature)-1] + flipped
+
+	tests := []struct {
+		name      string
+		fileID    uint
+		expires   int64
+		signature string
+		want      bool
+	}{
+		{"valid", 42, expires.Unix(), signature, true},
+		{"expired", 42, expired.Unix(), expiredSignature, false},
+		{"extended expiry", 42, expires.Add(time.Hour).Unix(), signature, false},
+		{"other file", 43, expires.Unix(), signature, false},
+		{"tampered signature", 42, expires.Unix(), tampered, false},
+		{"truncated signature", 42, expires.Unix(), signature[:32], false},
+		{"not hex", 42, expires.Unix(), strings.Repeat("z", len(signature)), false},
+		{"empty signature", 42, expires.Unix(), "", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := VerifyDownload(tt.fileID, tt.expires, tt.signature); got != tt.want {
+				t.Errorf("VerifyDownload(%d, %d, %q) = %v, want %v", tt.fileID, tt.expires, tt.signature, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDownloadSecretFallback(t *testing.T) {
+	expires := time.Now().Add(time.Hour)
+
+	t.Setenv("DOWNLOAD_LINK_SECRET", "")
+	t.Setenv("JWT_SECRET", "jwt-secret")
+	signature, err := SignDownload(7, expires)
+	if err != nil {
+		t.Fatalf("SignDownload with only JWT_SECRET: %v", err)
+	}
+	if !VerifyDownload(7, expires.Unix(), signature) {
+		t.Fatal("link signed with JWT_SECRET was rejected")
+	}
+
+	// Setting a dedicated secret revokes links signed with JWT_SECRET
+	t.Setenv("DOWNLOAD_LINK_SECRET", "download-secret")
+	if VerifyDownload(7, expires.Unix(), signature) {
+		t.Fatal("link signed with JWT_SECRET accepted after DOWNLOAD_LINK_SECRET was set")
+	}
+
+	t.Setenv("JWT_SECRET", "")
+	t.Setenv("DOWNLOAD_LINK_SECRET", "")
+	if _, err := SignDownload(7, expires); err == nil {
+		t.Fatal("SignDownload succeeded without a secret")
+	}
+	if VerifyDownload(7, expires.Unix(), signature) {
+		t.Fatal("VerifyDownload succeeded without a secret")
+	}
+}
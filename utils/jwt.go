@@ -1,6 +1,8 @@
 package utils
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"os"
 	"time"
@@ -9,23 +11,48 @@ import (
 	"golang.org/x/crypto/bcrypt"
 )
 
-// GenerateJWT creates a JWT token for a user
-func GenerateJWT(userID uint, role string) (string, error) {
+// AccessTokenTTL is how long an access token is valid. Clients use their
+// refresh token to get a new one.
+const AccessTokenTTL = 15 * time.Minute
+
+// GenerateJWT creates a short-lived access token for a user's session. The
+// token is only accepted while the user's token version is unchanged and
+// its ID (jti) has not been revoked. It returns the token and its expiry.
+func GenerateJWT(userID uint, role string, tokenVersion int, sessionID string) (string, time.Time, error) {
+	jti, err := RandomToken(16)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(AccessTokenTTL)
+
 	// Create a new JWT token with user information and expiration time
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": userID,
-		"role":    role,
-		"exp":     time.Now().Add(24 * time.Hour).Unix(), // 24 hours expiration
+		"user_id":       userID,
+		"role":          role,
+		"token_version": tokenVersion,
+		"sid":           sessionID,
+		"jti":           jti,
+		"exp":           expiresAt.Unix(),
 	})
 
 	// Get JWT secret from environment variable
 	secret := os.Getenv("JWT_SECRET")
 	if secret == "" {
-		return "", errors.New("JWT_SECRET is not set in environment")
+		return "", time.Time{}, errors.New("JWT_SECRET is not set in environment")
 	}
 
 	// Sign and return the token
-	return token.SignedString([]byte(secret))
+	signed, err := token.SignedString([]byte(secret))
+	return signed, expiresAt, err
+}
+
+// RandomToken returns n random bytes as a hex string
+func RandomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
 }
 
 // HashPassword hashes a password using bcrypt
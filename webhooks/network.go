@@ -0,0 +1,95 @@
+package webhooks
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for webhook URLs that resolve to loopback,
+// private, link-local or other internal addresses. Delivering to them would
+// let users probe the server's own network.
+var ErrForbiddenAddress = errors.New("webhook address is not publicly routable")
+
+// sharedAddressSpace is carrier-grade NAT (RFC 6598), which net.IP does not
+// classify as private
+var sharedAddressSpace = &net.IPNet{IP: net.IPv4(100, 64, 0, 0), Mask: net.CIDRMask(10, 32)}
+
+// newClient returns the HTTP client for deliveries. The address is checked
+// when each connection is dialled, after DNS resolution, so a host name that
+// changes what it resolves to (DNS rebinding) or a redirect cannot reach an
+// internal address.
+func newClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: 5 * time.Second,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			return checkIP(net.ParseIP(host))
+		},
+	}
+	return &http.Client{
+		Timeout: 10 * time.Second,
+		Transport: &http.Transport{
+			// No proxy, since the dialer would only check the proxy's address
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: 5 * time.Second,
+			MaxIdleConns:        100,
+			IdleConnTimeout:     90 * time.Second,
+		},
+	}
+}
+
+// CheckURL rejects webhook URLs whose host resolves to an internal address,
+// so users find out when subscribing rather than when deliveries fail.
+// Deliveries check again when connecting.
+func CheckURL(ctx context.Context, rawURL string) error {
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+	host := parsed.Hostname()
+	if ip := net.ParseIP(host); ip != nil {
+		return checkIP(ip)
+	}
+
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return fmt.Errorf("failed to resolve %s: %w", host, err)
+	}
+	for _, addr := range addrs {
+		if err := checkIP(addr.IP); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkIP returns ErrForbiddenAddress unless ip is publicly routable.
+// WEBHOOK_ALLOW_PRIVATE_NETWORKS=true lifts the restriction for development
+// against local receivers.
+func checkIP(ip net.IP) error {
+	if os.Getenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS") == "true" {
+		return nil
+	}
+	if ip == nil {
+		return ErrForbiddenAddress
+	}
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsInterfaceLocalMulticast() || ip.IsMulticast() ||
+		sharedAddressSpace.Contains(ip) || ip.Equal(net.IPv4bcast) {
+		return ErrForbiddenAddress
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package webhooks
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCheckIP(t *testing.T) {
+	forbidden := []string{
+		"127.0.0.1", "10.1.2.3", "172.16.0.1", "192.168.1.1", "169.254.169.254",
+		"100.64.0.1", "0.0.0.0", "255.255.255.255", "224.0.0.1", "::1", "fe80::1", "fd00::1",
+		"::ffff:127.0.0.1",
+	}
+	for _, addr := range forbidden {
+		if err := checkIP(net.ParseIP(addr)); !errors.Is(err, ErrForbiddenAddress) {
+			t.Errorf("checkIP(%s) = %v, want ErrForbiddenAddress", addr, err)
+		}
+	}
+
+	for _, addr := range []string{"93.184.216.34", "2606:4700::1111"} {
+		if err := checkIP(net.ParseIP(addr)); err != nil {
+			t.Errorf("checkIP(%s) = %v, want nil", addr, err)
+		}
+	}
+}
+
+func TestClientRefusesLoopback(t *testing.T) {
+	reached := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached = true
+	}))
+	defer server.Close()
+
+	resp, err := newClient().Get(server.URL)
+	if err == nil {
+		resp.Body.Close()
+	}
+	if !errors.Is(err, ErrForbiddenAddress) || reached {
+		t.Fatalf("delivery to %s: err = %v, reached = %v", server.URL, err, reached)
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE_NETWORKS", "true")
+	resp, err = newClient().Get(server.URL)
+	if err != nil {
+		t.Fatalf("delivery with private networks allowed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestCheckURLLiteral(t *testing.T) {
+	if err := CheckURL(t.Context(), "http://127.0.0.1:8080/hook"); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("CheckURL(loopback) = %v", err)
+	}
+	if err := CheckURL(t.Context(), "https://[::1]/hook"); !errors.Is(err, ErrForbiddenAddress) {
+		t.Fatalf("CheckURL(::1) = %v", err)
+	}
+}
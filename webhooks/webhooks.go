@@ -0,0 +1,238 @@
+package webhooks
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/events"
+	"github.com/Chamanthra/TaskManager/models"
+)
+
+const (
+	maxAttempts = 10
+	maxBackoff  = 12 * time.Hour
+)
+
+var client = newClient()
+
+// payload is the JSON body POSTed to webhook URLs
+type payload struct {
+	Event      string      `json:"event"`
+	TaskID     uint        `json:"task_id,omitempty"`
+	ActorID    uint        `json:"actor_id,omitempty"`
+	OccurredAt time.Time   `json:"occurred_at"`
+	Data       interface{} `json:"data"`
+}
+
+// Matches reports whether an event type passes a webhook's comma-separated
+// filter. Filters may be exact types, "*" or a prefix wildcard like "task.*".
+func Matches(filter, eventType string) bool {
+	for _, f := range strings.Split(filter, ",") {
+		f = strings.TrimSpace(f)
+		switch {
+		case f == "*" || f == eventType:
+			return true
+		case strings.HasSuffix(f, ".*") && strings.HasPrefix(eventType, strings.TrimSuffix(f, "*")):
+			return true
+		}
+	}
+	return false
+}
+
+// ValidFilter reports whether every entry of a filter names a known event
+func ValidFilter(filter string) bool {
+	for _, f := range strings.Split(filter, ",") {
+		f = strings.TrimSpace(f)
+		known := false
+		for _, eventType := range events.Types {
+			if Matches(f, eventType) {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return false
+		}
+	}
+	return filter != ""
+}
+
+// HandleEvent queues a delivery to every active webhook of the task's owner,
+// and of admins, whose filter matches the event
+func HandleEvent(event events.Event) error {
+	var hooks []models.Webhook
+	err := config.DB.
+		Joins("JOIN users ON users.id = webhooks.user_id").
+		Joins("LEFT JOIN roles ON roles.id = users.role_id").
+		Where("webhooks.active = ? AND (webhooks.user_id = ? OR roles.is_admin = ?)", true, event.OwnerID, true).
+		Find(&hooks).Error
+	if err != nil {
+		return fmt.Errorf("webhooks: failed to load subscriptions: %w", err)
+	}
+
+	return enqueue(hooks, payload{
+		Event:      event.Type,
+		TaskID:     event.TaskID,
+		ActorID:    event.ActorID,
+		OccurredAt: event.OccurredAt,
+		Data:       event.Data,
+	})
+}
+
+// DeliverNotification sends a notification to the recipient's own webhooks.
+// It is registered as the notification deliverer for the webhook channel.
+func DeliverNotification(notification models.Notification) error {
+	var hooks []models.Webhook
+	if err := config.DB.Where("user_id = ? AND active = ?", notification.UserID, true).Find(&hooks).Error; err != nil {
+		return err
+	}
+
+	return enqueue(hooks, payload{
+		Event:      events.NotificationCreated,
+		TaskID:     notification.TaskID,
+		OccurredAt: notification.CreatedAt,
+		Data:       notification,
+	})
+}
+
+// enqueue queues a delivery to every hook whose filter matches. The
+// deliveries are created together, so a failure leaves none to duplicate
+// when the event is retried.
+func enqueue(hooks []models.Webhook, p payload) error {
+	var deliveries []models.WebhookDelivery
+	var body []byte
+	for _, hook := range hooks {
+		if !Matches(hook.Events, p.Event) {
+			continue
+		}
+
+		if body == nil {
+			var err error
+			if body, err = json.Marshal(p); err != nil {
+				return fmt.Errorf("webhooks: failed to encode %s payload: %w", p.Event, err)
+			}
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			WebhookID:     hook.ID,
+			Event:         p.Event,
+			Payload:       string(body),
+			Status:        models.DeliveryPending,
+			NextAttemptAt: time.Now(),
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+
+	if err := config.DB.Omit("Webhook").Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("webhooks: failed to queue %s deliveries: %w", p.Event, err)
+	}
+	return nil
+}
+
+// ProcessPending attempts every delivery that is due
+func ProcessPending(ctx context.Context) error {
+	var deliveries []models.WebhookDelivery
+	if err := config.DB.WithContext(ctx).Preload("Webhook").
+		Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, time.Now()).
+		Order("next_attempt_at").Limit(100).Find(&deliveries).Error; err != nil {
+		return err
+	}
+
+	for i := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		Attempt(&deliveries[i])
+	}
+	return nil
+}
+
+// Attempt sends a delivery once and records the outcome, scheduling a retry
+// with exponential backoff or dead-lettering it after maxAttempts
+func Attempt(delivery *models.WebhookDelivery) {
+	statusCode, err := send(delivery.Webhook, delivery.ID, delivery.Event, []byte(delivery.Payload))
+
+	delivery.Attempts++
+	delivery.LastStatusCode = statusCode
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &now
+		delivery.LastError = ""
+	} else {
+		delivery.LastError = err.Error()
+		if delivery.Attempts >= maxAttempts {
+			delivery.Status = models.DeliveryDead
+		} else {
+			delivery.NextAttemptAt = time.Now().Add(backoff(delivery.Attempts))
+		}
+	}
+
+	if err := config.DB.Omit("Webhook").Save(delivery).Error; err != nil {
+		log.Printf("webhooks: failed to record delivery %d: %v", delivery.ID, err)
+	}
+}
+
+// send posts a payload and returns the response status. The response body is
+// discarded, since webhook URLs are chosen by users and responses must not be
+// readable through the delivery log.
+func send(hook models.Webhook, deliveryID uint, event string, body []byte) (int, error) {
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "TaskManager-Webhook/1.0")
+	req.Header.Set("X-Webhook-Event", event)
+	req.Header.Set("X-Webhook-Delivery", strconv.FormatUint(uint64(deliveryID), 10))
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+Sign(hook.Secret, timestamp, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	// Drain a little so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 4096))
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("endpoint returned %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// Sign computes the hex HMAC-SHA256 of "<timestamp>.<body>" with the
+// webhook secret. Receivers recompute it to verify X-Webhook-Signature.
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// backoff doubles the wait after each attempt, starting at 30 seconds
+func backoff(attempts int) time.Duration {
+	d := 30 * time.Second << (attempts - 1)
+	if d <= 0 || d > maxBackoff {
+		return maxBackoff
+	}
+	return d
+}
@@ -0,0 +1,165 @@
+package workers
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/mailer"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/notifications"
+	"gorm.io/gorm"
+)
+
+const (
+	maxEmailAttempts = 8
+	maxEmailBackoff  = 6 * time.Hour
+)
+
+// deliverEmails queues any due digests, then sends pending emails
+func deliverEmails(ctx context.Context) error {
+	if err := sendDigests(ctx); err != nil {
+		return err
+	}
+	return sendPendingEmails(ctx)
+}
+
+// sendPendingEmails sends queued emails, retrying temporary failures with
+// exponential backoff and giving up on permanent ones such as bounces
+func sendPendingEmails(ctx context.Context) error {
+	db := config.DB.WithContext(ctx)
+
+	var deliveries []models.EmailDelivery
+	if err := db.Where("status = ? AND next_attempt_at <= ?", models.EmailPending, time.Now()).
+		Order("next_attempt_at").Limit(100).Find(&deliveries).Error; err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		err := mailer.Send(mailer.Message{
+			To:      delivery.To,
+			Subject: delivery.Subject,
+			Text:    delivery.TextBody,
+			HTML:    delivery.HTMLBody,
+		})
+
+		recordAttempt(&delivery, err)
+		if err != nil {
+			log.Printf("email: delivery %d to %s failed (attempt %d): %v", delivery.ID, delivery.To, delivery.Attempts, err)
+		}
+
+		if err := db.Save(&delivery).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordAttempt updates a delivery with the outcome of sending it: sent,
+// scheduled for a retry, or failed for good after a permanent error or too
+// many attempts
+func recordAttempt(delivery *models.EmailDelivery, err error) {
+	delivery.Attempts++
+	if err == nil {
+		now := time.Now()
+		delivery.Status = models.EmailSent
+		delivery.SentAt = &now
+		delivery.LastError = ""
+		return
+	}
+
+	delivery.LastError = err.Error()
+	if mailer.IsPermanent(err) || delivery.Attempts >= maxEmailAttempts {
+		delivery.Status = models.EmailFailed
+	} else {
+		delivery.NextAttemptAt = time.Now().Add(emailBackoff(delivery.Attempts))
+	}
+}
+
+// emailBackoff doubles the wait after each attempt, starting at one minute
+func emailBackoff(attempts int) time.Duration {
+	backoff := time.Minute << (attempts - 1)
+	if backoff <= 0 || backoff > maxEmailBackoff {
+		return maxEmailBackoff
+	}
+	return backoff
+}
+
+// sendDigests batches unread, not yet emailed notifications into one email
+// for each user whose hourly or daily digest is due
+func sendDigests(ctx context.Context) error {
+	var settings []models.NotificationSettings
+	if err := config.DB.WithContext(ctx).Where("email_digest IN ?", []string{models.DigestHourly, models.DigestDaily}).Find(&settings).Error; err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, s := range settings {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		period := time.Hour
+		if s.EmailDigest == models.DigestDaily {
+			period = 24 * time.Hour
+		}
+		if s.LastDigestAt != nil && now.Sub(*s.LastDigestAt) < period {
+			continue
+		}
+
+		if err := sendDigest(s.UserID, now); err != nil {
+			log.Printf("email: digest for user %d failed: %v", s.UserID, err)
+		}
+	}
+	return nil
+}
+
+func sendDigest(userID uint, now time.Time) error {
+	var user models.User
+	if err := config.DB.First(&user, userID).Error; err != nil {
+		return err
+	}
+
+	// Only types the user routes to email go into the digest
+	var emailTypes []string
+	config.DB.Model(&models.NotificationPreference{}).
+		Where("user_id = ? AND channel = ?", userID, models.ChannelEmail).
+		Pluck("type", &emailTypes)
+
+	var pending []models.Notification
+	if user.Email != "" && len(emailTypes) > 0 {
+		if err := config.DB.Preload("Task").
+			Where("user_id = ? AND status = ? AND emailed_at IS NULL AND type IN ?", userID, "unread", emailTypes).
+			Order("created_at").Find(&pending).Error; err != nil {
+			return err
+		}
+	}
+
+	return config.DB.Transaction(func(tx *gorm.DB) error {
+		if len(pending) > 0 {
+			items := make([]mailer.Item, 0, len(pending))
+			ids := make([]uint, 0, len(pending))
+			for _, n := range pending {
+				items = append(items, mailer.Item{Message: n.Message, TaskTitle: n.Task.Title, CreatedAt: n.CreatedAt})
+				ids = append(ids, n.ID)
+			}
+
+			msg, err := mailer.RenderDigest(user.Email, user.UserName, items)
+			if err != nil {
+				return err
+			}
+			if err := notifications.QueueEmail(tx, userID, msg); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Notification{}).Where("id IN ?", ids).Update("emailed_at", now).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.NotificationSettings{}).Where("user_id = ?", userID).Update("last_digest_at", now).Error
+	})
+}
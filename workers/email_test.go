@@ -0,0 +1,50 @@
+package workers
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/mailer"
+	"github.com/Chamanthra/TaskManager/mailer/mailertest"
+	"github.com/Chamanthra/TaskManager/models"
+)
+
+func attempt(t *testing.T, rcptReply string) models.EmailDelivery {
+	t.Helper()
+	server := mailertest.NewServer(t)
+	if rcptReply != "" {
+		server.RejectRecipients(rcptReply)
+	}
+
+	delivery := models.EmailDelivery{To: "carol@example.com", Subject: "Hi", Status: models.EmailPending}
+	err := mailer.Send(mailer.Message{To: delivery.To, Subject: delivery.Subject, Text: "Hi"})
+	recordAttempt(&delivery, err)
+	return delivery
+}
+
+func TestRecordAttemptSent(t *testing.T) {
+	delivery := attempt(t, "")
+	if delivery.Status != models.EmailSent || delivery.SentAt == nil || delivery.Attempts != 1 {
+		t.Fatalf("delivery = %+v, want sent", delivery)
+	}
+}
+
+func TestRecordAttemptPermanentFailureNotRetried(t *testing.T) {
+	delivery := attempt(t, "550 No such user")
+	if delivery.Status != models.EmailFailed {
+		t.Fatalf("status = %s after a 5xx reply, want %s", delivery.Status, models.EmailFailed)
+	}
+	if delivery.LastError == "" {
+		t.Error("LastError not recorded")
+	}
+}
+
+func TestRecordAttemptTemporaryFailureRetried(t *testing.T) {
+	delivery := attempt(t, "451 Try again later")
+	if delivery.Status != models.EmailPending {
+		t.Fatalf("status = %s after a 4xx reply, want %s", delivery.Status, models.EmailPending)
+	}
+	if !delivery.NextAttemptAt.After(time.Now()) {
+		t.Errorf("NextAttemptAt = %v, want a retry in the future", delivery.NextAttemptAt)
+	}
+}
@@ -0,0 +1,37 @@
+package workers
+
+import (
+	"time"
+
+	"github.com/Chamanthra/TaskManager/files"
+	"github.com/Chamanthra/TaskManager/realtime"
+	"github.com/Chamanthra/TaskManager/sessions"
+)
+
+// RegisterJobs adds the application's background jobs to the scheduler
+func RegisterJobs(s *Scheduler) error {
+	jobs := []struct {
+		name    string
+		spec    string
+		timeout time.Duration
+		run     JobFunc
+	}{
+		{"due-date-reminders", "*/15 * * * *", 10 * time.Minute, checkDueTasks},
+		{"email-delivery", "* * * * *", 5 * time.Minute, deliverEmails},
+		{"webhook-delivery", "@every 5s", 5 * time.Minute, deliverWebhooks},
+		{"notification-retention", "0 3 * * *", 30 * time.Minute, pruneReadNotifications},
+		{"outbox-retention", "30 3 * * *", 30 * time.Minute, prunePublishedEvents},
+		{"upload-expiry", "@hourly", 30 * time.Minute, files.ExpireUploads},
+		{"file-rescan", "*/15 * * * *", 10 * time.Minute, files.RescanPending},
+		{"storage-check", "0 4 * * *", time.Hour, files.StorageCheck},
+		{"token-retention", "15 3 * * *", 30 * time.Minute, sessions.PruneExpired},
+		{"realtime-retention", "@hourly", 10 * time.Minute, realtime.PruneStoredPayloads},
+	}
+
+	for _, job := range jobs {
+		if err := s.Register(job.name, job.spec, job.timeout, job.run); err != nil {
+			return err
+		}
+	}
+	return nil
+}
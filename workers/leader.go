@@ -0,0 +1,123 @@
+package workers
+
+import (
+	"context"
+	"database/sql"
+	"hash/fnv"
+	"log"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+)
+
+// leaderCheckInterval is how often a follower retries the leader lock and how
+// often the leader checks that its lock connection is still alive
+const leaderCheckInterval = 5 * time.Second
+
+// advisoryLock is a Postgres session-level advisory lock held on a dedicated
+// connection. Postgres releases it automatically if the connection dies.
+type advisoryLock struct {
+	conn *sql.Conn
+	key  int64
+}
+
+// lockKey maps a lock name into the advisory lock key space
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("taskmanager:" + name))
+	return int64(h.Sum64())
+}
+
+// tryLock attempts to take the named advisory lock without waiting. It
+// returns nil if another session holds it.
+func tryLock(ctx context.Context, name string) (*advisoryLock, error) {
+	sqlDB, err := config.DB.DB()
+	if err != nil {
+		return nil, err
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	key := lockKey(name)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", key).Scan(&acquired); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !acquired {
+		conn.Close()
+		return nil, nil
+	}
+	return &advisoryLock{conn: conn, key: key}, nil
+}
+
+// alive reports whether the connection holding the lock is still usable
+func (l *advisoryLock) alive(ctx context.Context) bool {
+	return l.conn.PingContext(ctx) == nil
+}
+
+// release unlocks and returns the connection to the pool
+func (l *advisoryLock) release() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	l.conn.Close()
+}
+
+// RunAsLeader blocks until ctx is cancelled, running fn only while this
+// instance holds the named leader lock. If the leader's database connection
+// dies, fn's context is cancelled and another instance takes over once
+// Postgres drops the old session.
+func RunAsLeader(ctx context.Context, name string, fn func(ctx context.Context)) {
+	for {
+		lock, err := tryLock(ctx, name)
+		if err != nil && ctx.Err() == nil {
+			log.Printf("leader: failed to try %s lock: %v", name, err)
+		}
+
+		if lock != nil {
+			log.Printf("leader: acquired %s lock", name)
+			lead(ctx, lock, fn)
+			log.Printf("leader: released %s lock", name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(leaderCheckInterval):
+		}
+	}
+}
+
+// lead runs fn until ctx is cancelled or the lock connection fails
+func lead(ctx context.Context, lock *advisoryLock, fn func(ctx context.Context)) {
+	defer lock.release()
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	done := make(chan struct{})
+	go func() {
+		fn(leaderCtx)
+		close(done)
+	}()
+
+	ticker := time.NewTicker(leaderCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			if !lock.alive(leaderCtx) {
+				log.Printf("leader: lost lock connection, stepping down")
+				cancel()
+				<-done
+				return
+			}
+		}
+	}
+}
@@ -1,43 +1,124 @@
 package workers
 
 import (
+	"context"
 	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/Chamanthra/TaskManager/config"
 	"github.com/Chamanthra/TaskManager/models"
+	"github.com/Chamanthra/TaskManager/notifications"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
-func StartNotificationWorker() {
-	ticker := time.NewTicker(24 * time.Hour) // Check daily
-	defer ticker.Stop()
+// defaultReminderSchedule is used when REMINDER_SCHEDULE is not set: a week,
+// a day and an hour before the due date
+var defaultReminderSchedule = []time.Duration{7 * 24 * time.Hour, 24 * time.Hour, time.Hour}
 
-	for {
-		select {
-		case <-ticker.C:
-			checkDueTasks()
+// checkDueTasks sends the most urgent unsent reminder for every open task
+// approaching its due date, and one reminder per day once it is overdue
+func checkDueTasks(ctx context.Context) error {
+	db := config.DB.WithContext(ctx)
+	now := time.Now()
+	schedule := reminderSchedule()
+
+	var tasks []models.Task
+	err := db.Where("status NOT IN ? AND due_date > ? AND due_date <= ?",
+		[]string{"done", "archived"}, time.Unix(0, 0), now.Add(schedule[0])).Find(&tasks).Error
+	if err != nil {
+		return fmt.Errorf("failed to load due tasks: %w", err)
+	}
+
+	for _, task := range tasks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		stage, message := reminderStage(task, schedule, now)
+
+		// Record the reminder and queue the notification together, so a
+		// failure cannot send it twice or mark it sent without sending it
+		err := db.Transaction(func(tx *gorm.DB) error {
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&models.TaskReminder{TaskID: task.ID, Stage: stage})
+			if result.Error != nil || result.RowsAffected == 0 {
+				return result.Error // Already sent
+			}
+			return notifications.Enqueue(tx, task.UserID, task.ID, models.NotificationDueDate, message)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to queue reminder for task %d: %w", task.ID, err)
 		}
 	}
+	return nil
 }
 
-func checkDueTasks() {
-	var tasks []models.Task
-	oneWeekFromNow := time.Now().Add(7 * 24 * time.Hour)
-
-	config.DB.Where("due_date BETWEEN ? AND ? AND notified = ?",
-		time.Now(), oneWeekFromNow, false).Find(&tasks)
+// reminderStage picks the reminder that applies to a task right now: the
+// shortest schedule offset the due date falls within, or the number of days
+// it has been overdue
+func reminderStage(task models.Task, schedule []time.Duration, now time.Time) (string, string) {
+	remaining := task.DueDate.Sub(now)
+	if remaining <= 0 {
+		days := int(-remaining / (24 * time.Hour))
+		return fmt.Sprintf("overdue:%d", days), fmt.Sprintf("Task '%s' is overdue!", task.Title)
+	}
 
-	for _, task := range tasks {
-		notification := models.Notification{
-			Message: fmt.Sprintf("Task '%s' is due in less than a week!", task.Title),
-			UserID:  task.UserID,
-			TaskID:  task.ID,
-			Type:    "due_date",
+	stage := schedule[0]
+	for _, offset := range schedule {
+		if remaining <= offset {
+			stage = offset
 		}
+	}
+	return "before:" + stage.String(), fmt.Sprintf("Task '%s' is due in less than %s!", task.Title, humanizeDuration(stage))
+}
+
+// reminderSchedule parses REMINDER_SCHEDULE, a comma-separated list of
+// durations before the due date such as "168h,24h,1h", longest first
+func reminderSchedule() []time.Duration {
+	value := os.Getenv("REMINDER_SCHEDULE")
+	if value == "" {
+		return defaultReminderSchedule
+	}
 
-		if err := config.DB.Create(&notification).Error; err == nil {
-			// Mark as notified if creation succeeded
-			config.DB.Model(&task).Update("notified", true)
+	var schedule []time.Duration
+	for _, part := range strings.Split(value, ",") {
+		offset, err := time.ParseDuration(strings.TrimSpace(part))
+		if err != nil || offset <= 0 {
+			log.Printf("reminders: ignoring invalid REMINDER_SCHEDULE entry %q", part)
+			continue
 		}
+		schedule = append(schedule, offset)
+	}
+	if len(schedule) == 0 {
+		return defaultReminderSchedule
+	}
+
+	sort.Slice(schedule, func(i, j int) bool { return schedule[i] > schedule[j] })
+	return schedule
+}
+
+func humanizeDuration(d time.Duration) string {
+	switch {
+	case d == 7*24*time.Hour:
+		return "a week"
+	case d == time.Hour:
+		return "an hour"
+	case d%(24*time.Hour) == 0:
+		return plural(int(d/(24*time.Hour)), "day")
+	case d%time.Hour == 0:
+		return plural(int(d/time.Hour), "hour")
+	default:
+		return plural(int(d/time.Minute), "minute")
+	}
+}
+
+func plural(n int, unit string) string {
+	if n == 1 {
+		return "a " + unit
 	}
+	return fmt.Sprintf("%d %ss", n, unit)
 }
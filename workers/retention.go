@@ -0,0 +1,55 @@
+package workers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+)
+
+// defaultRetentionDays is used when NOTIFICATION_RETENTION_DAYS is not set
+const defaultRetentionDays = 90
+
+// pruneReadNotifications deletes read notifications older than the retention
+// period. Unread notifications are never pruned.
+func pruneReadNotifications(ctx context.Context) error {
+	days := defaultRetentionDays
+	if value := os.Getenv("NOTIFICATION_RETENTION_DAYS"); value != "" {
+		if parsed, err := strconv.Atoi(value); err == nil && parsed > 0 {
+			days = parsed
+		}
+	}
+	cutoff := time.Now().AddDate(0, 0, -days)
+
+	result := config.DB.WithContext(ctx).Where("status = ? AND created_at < ?", "read", cutoff).Delete(&models.Notification{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to prune notifications: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("retention: pruned %d read notifications older than %d days", result.RowsAffected, days)
+	}
+	return nil
+}
+
+// outboxRetention is how long published outbox events are kept for debugging
+const outboxRetention = 7 * 24 * time.Hour
+
+// prunePublishedEvents deletes outbox events that were published more than a
+// week ago. Unpublished events are kept until the relay delivers them.
+func prunePublishedEvents(ctx context.Context) error {
+	result := config.DB.WithContext(ctx).
+		Where("published_at IS NOT NULL AND published_at < ?", time.Now().Add(-outboxRetention)).
+		Delete(&models.OutboxEvent{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to prune outbox events: %w", result.Error)
+	}
+	if result.RowsAffected > 0 {
+		log.Printf("retention: pruned %d published outbox events", result.RowsAffected)
+	}
+	return nil
+}
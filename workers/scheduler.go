@@ -0,0 +1,235 @@
+package workers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/Chamanthra/TaskManager/config"
+	"github.com/Chamanthra/TaskManager/models"
+	"github.com/robfig/cron/v3"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrUnknownJob = errors.New("unknown job")
+	ErrJobRunning = errors.New("job is already running")
+)
+
+// JobFunc is the body of a scheduled job. It should stop when ctx is done.
+type JobFunc func(ctx context.Context) error
+
+// Job is a named unit of background work run on a cron schedule
+type Job struct {
+	Name     string
+	Spec     string // Cron expression, e.g. "*/15 * * * *" or "@every 5s"
+	Timeout  time.Duration
+	Run      JobFunc
+	schedule cron.Schedule
+}
+
+// Scheduler runs registered jobs on their schedules, persisting last-run and
+// next-run times so schedules survive restarts
+type Scheduler struct {
+	mu      sync.Mutex
+	jobs    map[string]*Job
+	next    map[string]time.Time
+	running map[string]bool
+	ctx     context.Context
+	wg      sync.WaitGroup
+}
+
+// DefaultScheduler holds the application's background jobs
+var DefaultScheduler = NewScheduler()
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{
+		jobs:    make(map[string]*Job),
+		next:    make(map[string]time.Time),
+		running: make(map[string]bool),
+		ctx:     context.Background(),
+	}
+}
+
+// Register adds a job. It fails if the cron expression is invalid.
+func (s *Scheduler) Register(name, spec string, timeout time.Duration, run JobFunc) error {
+	schedule, err := cron.ParseStandard(spec)
+	if err != nil {
+		return fmt.Errorf("job %s: invalid schedule %q: %w", name, spec, err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.jobs[name] = &Job{Name: name, Spec: spec, Timeout: timeout, Run: run, schedule: schedule}
+	return nil
+}
+
+// Start runs jobs until ctx is cancelled, then waits for running jobs to
+// finish. When several server instances run, only the one holding the
+// scheduler leader lock runs jobs on schedule.
+func (s *Scheduler) Start(ctx context.Context) {
+	s.mu.Lock()
+	s.ctx = ctx
+	s.mu.Unlock()
+
+	RunAsLeader(ctx, "scheduler", s.loop)
+	s.wg.Wait()
+}
+
+// loop launches due jobs while this instance is the leader. Jobs that have
+// never run, or whose persisted next run was missed while no leader was
+// running, run straight away.
+func (s *Scheduler) loop(ctx context.Context) {
+	s.mu.Lock()
+	for name, job := range s.jobs {
+		var state models.ScheduledJob
+		if err := config.DB.Where("name = ?", name).Limit(1).Find(&state).Error; err != nil {
+			log.Printf("scheduler: failed to load state for %s: %v", name, err)
+		}
+		if state.Name == "" || state.Schedule != job.Spec {
+			s.next[name] = time.Now()
+		} else {
+			s.next[name] = state.NextRunAt
+		}
+	}
+	s.mu.Unlock()
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for name, next := range s.next {
+				if !now.Before(next) && !s.running[name] {
+					s.launch(ctx, s.jobs[name])
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// RunNow starts a job immediately, outside its schedule
+func (s *Scheduler) RunNow(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.jobs[name]
+	if !ok {
+		return ErrUnknownJob
+	}
+	if s.running[name] {
+		return ErrJobRunning
+	}
+	s.launch(s.ctx, job)
+	return nil
+}
+
+// Jobs returns the persisted state of every registered job
+func (s *Scheduler) Jobs() ([]models.ScheduledJob, error) {
+	s.mu.Lock()
+	names := make([]string, 0, len(s.jobs))
+	for name := range s.jobs {
+		names = append(names, name)
+	}
+	s.mu.Unlock()
+	sort.Strings(names)
+
+	var states []models.ScheduledJob
+	if err := config.DB.Where("name IN ?", names).Order("name").Find(&states).Error; err != nil {
+		return nil, err
+	}
+	return states, nil
+}
+
+// launch runs a job in the background. The caller must hold s.mu.
+func (s *Scheduler) launch(ctx context.Context, job *Job) {
+	s.running[job.Name] = true
+	s.wg.Add(1)
+
+	go func() {
+		defer s.wg.Done()
+
+		// The per-job lock keeps a manual run on one instance from
+		// overlapping a scheduled run on another
+		lock, err := tryLock(ctx, "job:"+job.Name)
+		if lock == nil {
+			if err != nil && ctx.Err() == nil {
+				log.Printf("scheduler: failed to lock job %s: %v", job.Name, err)
+			} else if err == nil {
+				log.Printf("scheduler: job %s is running on another instance, skipping", job.Name)
+			}
+			s.mu.Lock()
+			s.running[job.Name] = false
+			s.next[job.Name] = job.schedule.Next(time.Now())
+			s.mu.Unlock()
+			return
+		}
+		defer lock.release()
+
+		started := time.Now()
+		s.saveState(job, models.ScheduledJob{Running: true}, "running")
+
+		err = execute(ctx, job)
+
+		finished := time.Now()
+		next := job.schedule.Next(finished)
+		state := models.ScheduledJob{
+			LastRunAt:    &started,
+			NextRunAt:    next,
+			LastDuration: finished.Sub(started).Milliseconds(),
+		}
+		if err != nil {
+			state.LastError = err.Error()
+			log.Printf("scheduler: job %s failed: %v", job.Name, err)
+		}
+		s.saveState(job, state, "last_run_at", "next_run_at", "last_duration", "last_error", "running")
+
+		s.mu.Lock()
+		s.running[job.Name] = false
+		s.next[job.Name] = next
+		s.mu.Unlock()
+	}()
+}
+
+// execute runs the job body with its timeout, converting a panic into an error
+func execute(ctx context.Context, job *Job) (err error) {
+	if job.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, job.Timeout)
+		defer cancel()
+	}
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+			log.Printf("scheduler: job %s panicked: %v\n%s", job.Name, r, debug.Stack())
+		}
+	}()
+
+	return job.Run(ctx)
+}
+
+// saveState upserts the job's persisted state, updating only the given columns
+// when a row already exists
+func (s *Scheduler) saveState(job *Job, state models.ScheduledJob, columns ...string) {
+	state.Name = job.Name
+	state.Schedule = job.Spec
+	columns = append(columns, "schedule", "updated_at")
+	err := config.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "name"}},
+		DoUpdates: clause.AssignmentColumns(columns),
+	}).Create(&state).Error
+	if err != nil {
+		log.Printf("scheduler: failed to save state for %s: %v", job.Name, err)
+	}
+}
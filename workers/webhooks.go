@@ -0,0 +1,11 @@
+package workers
+
+import (
+	"context"
+
+	"github.com/Chamanthra/TaskManager/webhooks"
+)
+
+func deliverWebhooks(ctx context.Context) error {
+	return webhooks.ProcessPending(ctx)
+}